@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+const dayLayout = "2006-01-02"
+
+type Counter interface {
+	Increment(ctx context.Context, shortKey, visitorID string, at time.Time) error
+	Drain(ctx context.Context) ([]models.ClickCount, error)
+	Restore(ctx context.Context, counts []models.ClickCount) error
+}
+
+func bucketKey(shortKey string, day time.Time) string {
+	return shortKey + "|" + day.UTC().Format(dayLayout)
+}
+
+func parseBucketKey(key string) (string, time.Time, bool) {
+	shortKey, dayStr, ok := strings.Cut(key, "|")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	day, err := time.Parse(dayLayout, dayStr)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return shortKey, day, true
+}
+
+type memoryBucket struct {
+	clicks   int64
+	visitors map[string]struct{}
+}
+
+type MemoryCounter struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{buckets: make(map[string]*memoryBucket)}
+}
+
+func (c *MemoryCounter) Increment(ctx context.Context, shortKey, visitorID string, at time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := bucketKey(shortKey, at)
+	bucket, ok := c.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{visitors: make(map[string]struct{})}
+		c.buckets[key] = bucket
+	}
+
+	bucket.clicks++
+	bucket.visitors[visitorID] = struct{}{}
+	return nil
+}
+
+func (c *MemoryCounter) Drain(ctx context.Context) ([]models.ClickCount, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(dayLayout)
+
+	var counts []models.ClickCount
+	for key, bucket := range c.buckets {
+		shortKey, day, _ := parseBucketKey(key)
+
+		if bucket.clicks > 0 {
+			counts = append(counts, models.ClickCount{
+				ShortKey:       shortKey,
+				Day:            day,
+				Clicks:         bucket.clicks,
+				UniqueVisitors: int64(len(bucket.visitors)),
+			})
+			bucket.clicks = 0
+		}
+
+		// Visitors are kept for the rest of the day so the unique count stays
+		// absolute across flushes, then dropped once the day is over.
+		if day.Format(dayLayout) < yesterday {
+			delete(c.buckets, key)
+		}
+	}
+
+	return counts, nil
+}
+
+func (c *MemoryCounter) Restore(ctx context.Context, counts []models.ClickCount) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, count := range counts {
+		key := bucketKey(count.ShortKey, count.Day)
+		bucket, ok := c.buckets[key]
+		if !ok {
+			bucket = &memoryBucket{visitors: make(map[string]struct{})}
+			c.buckets[key] = bucket
+		}
+		bucket.clicks += count.Clicks
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+type CountryResolver interface {
+	Country(r *http.Request, ip string) string
+}
+
+// HeaderCountryResolver reads the country a trusted proxy resolved from the
+// client IP, for example with the Nginx GeoIP module. Clients can set the
+// header themselves, so it must only be used behind a proxy that overwrites
+// it. Requests without the header are resolved by Fallback.
+type HeaderCountryResolver struct {
+	Header   string
+	Fallback CountryResolver
+}
+
+func (h HeaderCountryResolver) Country(r *http.Request, ip string) string {
+	if r.Header.Get(h.Header) == "" && h.Fallback != nil {
+		return h.Fallback.Country(r, ip)
+	}
+	return normalizeCountry(r.Header.Get(h.Header))
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// IPCountryResolver looks the client IP up in a table of IP ranges. The zero
+// value knows no ranges and resolves every IP to an unknown country.
+type IPCountryResolver struct {
+	ranges []ipRange
+}
+
+// LoadIPCountryRanges reads a CSV of start_ip,end_ip,country rows, as in the
+// freely available IP to country databases. IPv4 and IPv6 ranges may be
+// mixed.
+func LoadIPCountryRanges(r io.Reader) (*IPCountryResolver, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	res := &IPCountryResolver{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		start, startErr := netip.ParseAddr(record[0])
+		end, endErr := netip.ParseAddr(record[1])
+		if startErr != nil || endErr != nil || end.Less(start) {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("invalid IP range on line %d", line)
+		}
+		res.ranges = append(res.ranges, ipRange{start: start.Unmap(), end: end.Unmap(), country: normalizeCountry(record[2])})
+	}
+
+	sort.Slice(res.ranges, func(i, j int) bool { return res.ranges[i].start.Less(res.ranges[j].start) })
+	return res, nil
+}
+
+func (res *IPCountryResolver) Country(r *http.Request, ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	// The last range starting at or before the IP is the only one that can
+	// contain it.
+	i := sort.Search(len(res.ranges), func(i int) bool { return addr.Less(res.ranges[i].start) }) - 1
+	if i < 0 || res.ranges[i].end.Less(addr) {
+		return ""
+	}
+	return res.ranges[i].country
+}
+
+func normalizeCountry(country string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if len(country) != 2 || country == "XX" {
+		return ""
+	}
+	return country
+}
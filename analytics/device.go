@@ -0,0 +1,39 @@
+package analytics
+
+import "strings"
+
+const (
+	DeviceBot     = "bot"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceUnknown = "unknown"
+)
+
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "curl", "wget", "python-requests", "go-http-client", "facebookexternalhit", "preview"}
+
+func DeviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case ua == "":
+		return DeviceUnknown
+	case containsAny(ua, botMarkers...):
+		return DeviceBot
+	case containsAny(ua, "ipad", "tablet", "kindle", "silk") || (strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return DeviceTablet
+	case containsAny(ua, "mobi", "iphone", "ipod", "android", "windows phone"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
+
+func containsAny(s string, substrings ...string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
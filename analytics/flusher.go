@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+type CounterFlusher struct {
+	counter  Counter
+	store    models.ClickCountStore
+	interval time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewCounterFlusher(counter Counter, store models.ClickCountStore, interval time.Duration) *CounterFlusher {
+	f := &CounterFlusher{
+		counter:  counter,
+		store:    store,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go f.run()
+
+	return f
+}
+
+func (f *CounterFlusher) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ctx := context.Background()
+
+	counts, err := f.counter.Drain(ctx)
+	if len(counts) > 0 {
+		if storeErr := f.store.AddClickCounts(counts); storeErr != nil {
+			if restoreErr := f.counter.Restore(ctx, counts); restoreErr != nil {
+				log.Printf("[Error] Lost %d click counts. ERROR: %s", len(counts), restoreErr.Error())
+			}
+			return storeErr
+		}
+	}
+
+	return err
+}
+
+func (f *CounterFlusher) Close() {
+	close(f.stop)
+	<-f.done
+}
+
+func (f *CounterFlusher) run() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.Flush(); err != nil {
+				log.Println("[Error] Could not flush click counts ", err)
+			}
+		case <-f.stop:
+			if err := f.Flush(); err != nil {
+				log.Println("[Error] Could not flush click counts on shutdown ", err)
+			}
+			return
+		}
+	}
+}
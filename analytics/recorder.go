@@ -0,0 +1,107 @@
+package analytics
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+type Recorder struct {
+	store         models.ClickStore
+	events        chan models.Click
+	flushRequests chan chan struct{}
+	batchSize     int
+	flushInterval time.Duration
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func NewRecorder(store models.ClickStore, bufferSize, batchSize int, flushInterval time.Duration) *Recorder {
+	r := &Recorder{
+		store:         store,
+		events:        make(chan models.Click, bufferSize),
+		flushRequests: make(chan chan struct{}),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+
+	go r.run()
+
+	return r
+}
+
+func (r *Recorder) Record(c models.Click) {
+	select {
+	case r.events <- c:
+	default:
+		log.Println("[Error] Click buffer full, dropping click for", c.ShortKey)
+	}
+}
+
+func (r *Recorder) Flush() {
+	ack := make(chan struct{})
+	select {
+	case r.flushRequests <- ack:
+		<-ack
+	case <-r.done:
+	}
+}
+
+func (r *Recorder) Close() {
+	r.closeOnce.Do(func() {
+		close(r.events)
+		<-r.done
+	})
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.Click, 0, r.batchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.store.InsertClicks(batch); err != nil {
+			log.Printf("[Error] Could not write %d clicks. ERROR: %s", len(batch), err.Error())
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case c, ok := <-r.events:
+			if !ok {
+				write()
+				return
+			}
+			batch = append(batch, c)
+			if len(batch) >= r.batchSize {
+				write()
+			}
+		case ack := <-r.flushRequests:
+			for drained := false; !drained; {
+				select {
+				case c, ok := <-r.events:
+					if ok {
+						batch = append(batch, c)
+					}
+					drained = !ok
+				default:
+					drained = true
+				}
+			}
+			write()
+			close(ack)
+		case <-ticker.C:
+			write()
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	dirtyBucketsKey  = "clicks:dirty"
+	clickCountPrefix = "clicks:count:"
+	visitorsPrefix   = "clicks:visitors:"
+	visitorsTTL      = 48 * time.Hour
+	drainBatchSize   = 500
+)
+
+type RedisCounter struct {
+	client *redis.Client
+}
+
+func NewRedisCounter(client *redis.Client) *RedisCounter {
+	return &RedisCounter{client: client}
+}
+
+func (c *RedisCounter) Increment(ctx context.Context, shortKey, visitorID string, at time.Time) error {
+	key := bucketKey(shortKey, at)
+
+	pipe := c.client.TxPipeline()
+	pipe.Incr(ctx, clickCountPrefix+key)
+	pipe.PFAdd(ctx, visitorsPrefix+key, visitorID)
+	pipe.Expire(ctx, visitorsPrefix+key, visitorsTTL)
+	pipe.SAdd(ctx, dirtyBucketsKey, key)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *RedisCounter) Drain(ctx context.Context) ([]models.ClickCount, error) {
+	var counts []models.ClickCount
+
+	for {
+		keys, err := c.client.SPopN(ctx, dirtyBucketsKey, drainBatchSize).Result()
+		if err != nil {
+			return counts, err
+		}
+		if len(keys) == 0 {
+			return counts, nil
+		}
+
+		for i, key := range keys {
+			shortKey, day, ok := parseBucketKey(key)
+			if !ok {
+				continue
+			}
+
+			clicks, err := c.client.GetDel(ctx, clickCountPrefix+key).Int64()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				remaining := make([]interface{}, 0, len(keys)-i)
+				for _, k := range keys[i:] {
+					remaining = append(remaining, k)
+				}
+				c.client.SAdd(ctx, dirtyBucketsKey, remaining...)
+				return counts, err
+			}
+
+			visitors, _ := c.client.PFCount(ctx, visitorsPrefix+key).Result()
+
+			counts = append(counts, models.ClickCount{
+				ShortKey:       shortKey,
+				Day:            day,
+				Clicks:         clicks,
+				UniqueVisitors: visitors,
+			})
+		}
+	}
+}
+
+func (c *RedisCounter) Restore(ctx context.Context, counts []models.ClickCount) error {
+	pipe := c.client.TxPipeline()
+	for _, count := range counts {
+		key := bucketKey(count.ShortKey, count.Day)
+		pipe.IncrBy(ctx, clickCountPrefix+key, count.Clicks)
+		pipe.SAdd(ctx, dirtyBucketsKey, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
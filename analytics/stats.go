@@ -0,0 +1,117 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+type Bucket struct {
+	Time   time.Time `json:"time"`
+	Clicks int64     `json:"clicks"`
+}
+
+type Count struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Stats reports the totals and daily clicks of whole days, and the hourly
+// clicks and top values of the clicks since DetailsFrom.
+type Stats struct {
+	ShortKey       string    `json:"short_key"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	DetailsFrom    time.Time `json:"details_from"`
+	TotalClicks    int64     `json:"total_clicks"`
+	UniqueVisitors int64     `json:"unique_visitors"`
+	Hourly         []Bucket  `json:"hourly"`
+	Daily          []Bucket  `json:"daily"`
+	TopReferrers   []Count   `json:"top_referrers"`
+	TopUserAgents  []Count   `json:"top_user_agents"`
+	TopCountries   []Count   `json:"top_countries"`
+}
+
+type StatsBuilder struct {
+	stats     Stats
+	topLimit  int
+	hourly    map[time.Time]int64
+	daily     map[time.Time]int64
+	referrers map[string]int64
+	agents    map[string]int64
+	countries map[string]int64
+}
+
+func NewStatsBuilder(shortKey string, from, to, detailsFrom time.Time, topLimit int) *StatsBuilder {
+	return &StatsBuilder{
+		stats:     Stats{ShortKey: shortKey, From: from, To: to, DetailsFrom: detailsFrom},
+		topLimit:  topLimit,
+		hourly:    make(map[time.Time]int64),
+		daily:     make(map[time.Time]int64),
+		referrers: make(map[string]int64),
+		agents:    make(map[string]int64),
+		countries: make(map[string]int64),
+	}
+}
+
+// AddDay counts the aggregated clicks of a day. Unique visitors are summed
+// over the days, so a visitor coming back on another day counts again.
+func (b *StatsBuilder) AddDay(c models.ClickCount) {
+	b.stats.TotalClicks += c.Clicks
+	b.stats.UniqueVisitors += c.UniqueVisitors
+	b.daily[c.Day.UTC()] += c.Clicks
+}
+
+// Add counts a single click towards the hourly clicks and the top values.
+func (b *StatsBuilder) Add(c models.Click) error {
+	b.hourly[c.ClickedAt.UTC().Truncate(time.Hour)]++
+	b.referrers[labelOrDefault(c.Referrer, "(direct)")]++
+	b.agents[labelOrDefault(c.UserAgent, "(unknown)")]++
+	b.countries[labelOrDefault(c.Country, "(unknown)")]++
+
+	return nil
+}
+
+func (b *StatsBuilder) Build() Stats {
+	stats := b.stats
+	stats.Hourly = sortedBuckets(b.hourly)
+	stats.Daily = sortedBuckets(b.daily)
+	stats.TopReferrers = topCounts(b.referrers, b.topLimit)
+	stats.TopUserAgents = topCounts(b.agents, b.topLimit)
+	stats.TopCountries = topCounts(b.countries, b.topLimit)
+	return stats
+}
+
+func sortedBuckets(counts map[time.Time]int64) []Bucket {
+	buckets := make([]Bucket, 0, len(counts))
+	for t, clicks := range counts {
+		buckets = append(buckets, Bucket{Time: t, Clicks: clicks})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Time.Before(buckets[j].Time) })
+	return buckets
+}
+
+func topCounts(counts map[string]int64, limit int) []Count {
+	top := make([]Count, 0, len(counts))
+	for value, clicks := range counts {
+		top = append(top, Count{Value: value, Clicks: clicks})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Clicks != top[j].Clicks {
+			return top[i].Clicks > top[j].Clicks
+		}
+		return top[i].Value < top[j].Value
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
+
+func labelOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/analytics"
+	"github.com/Conero007/url-shortener/cache"
+	"github.com/Conero007/url-shortener/models"
+)
+
+// InitializeAnalytics starts recording clicks. Countries are resolved from
+// the client IP with the ranges in countryDatabase, or read from
+// countryHeader when a trusted proxy set it.
+func (a *AppConfig) InitializeAnalytics(bufferSize, batchSize int, flushInterval time.Duration, countryHeader, countryDatabase string) error {
+	if flushInterval <= 0 {
+		return errors.New("flush interval must be positive")
+	}
+
+	if a.Clicks != nil {
+		a.Clicks.Close()
+	}
+	a.Clicks = analytics.NewRecorder(a.Store, bufferSize, batchSize, flushInterval)
+
+	byIP := &analytics.IPCountryResolver{}
+	if countryDatabase != "" {
+		if f, err := os.Open(countryDatabase); err != nil {
+			log.Println("[Error] Could not open country database ", err)
+		} else {
+			if byIP, err = analytics.LoadIPCountryRanges(f); err != nil {
+				log.Println("[Error] Could not load country database ", err)
+				byIP = &analytics.IPCountryResolver{}
+			}
+			f.Close()
+		}
+	}
+
+	a.countries = byIP
+	a.proxyCountries = analytics.HeaderCountryResolver{Header: countryHeader, Fallback: byIP}
+	return nil
+}
+
+func (a *AppConfig) InitializeClickCounters(flushInterval time.Duration) error {
+	if flushInterval <= 0 {
+		return errors.New("flush interval must be positive")
+	}
+
+	if redisCache, ok := a.Cache.(*cache.RedisCache); ok {
+		a.counter = analytics.NewRedisCounter(redisCache.Client)
+	} else {
+		a.counter = analytics.NewMemoryCounter()
+	}
+	a.counterFlusher = analytics.NewCounterFlusher(a.counter, a.Store, flushInterval)
+	return nil
+}
+
+func (a *AppConfig) FlushClickCounts() error {
+	if a.counterFlusher == nil {
+		return nil
+	}
+	a.wg.Wait()
+	return a.counterFlusher.Flush()
+}
+
+func recordClick(r *http.Request, shortKey string) {
+	ip := getClientIP(r)
+	userAgent := r.UserAgent()
+	now := time.Now().UTC()
+	visitor := visitorID(ip, userAgent)
+
+	countries := App.countries
+	if App.fromTrustedProxy(r) {
+		countries = App.proxyCountries
+	}
+
+	if App.counter != nil {
+		App.wg.Add(1)
+		go incrementClickCounter(App.counter, context.Background(), App.wg, shortKey, visitor, now)
+	}
+
+	if App.Clicks != nil {
+		App.Clicks.Record(models.Click{
+			ShortKey:    shortKey,
+			ClickedAt:   now,
+			Referrer:    truncate(r.Referer(), 2048),
+			UserAgent:   truncate(userAgent, 512),
+			Country:     countries.Country(r, ip),
+			DeviceClass: analytics.DeviceClass(userAgent),
+			VisitorID:   visitor,
+		})
+	}
+}
+
+func incrementClickCounter(c analytics.Counter, ctx context.Context, wg *sync.WaitGroup, shortKey, visitorID string, at time.Time) {
+	defer wg.Done()
+
+	if err := c.Increment(ctx, shortKey, visitorID, at); err != nil {
+		log.Println("[Error] Could not increment click counter ", err)
+	}
+}
+
+func visitorID(ip, userAgent string) string {
+	hash := sha256.Sum256([]byte(ip + "|" + userAgent))
+	return hex.EncodeToString(hash[:])
+}
+
+func truncate(s string, length int) string {
+	if len(s) > length {
+		return s[:length]
+	}
+	return s
+}
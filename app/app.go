@@ -2,84 +2,339 @@ package app
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
-	"github.com/Conero007/url-shortener/database"
-	"github.com/go-sql-driver/mysql"
+	"github.com/Conero007/url-shortener/analytics"
+	"github.com/Conero007/url-shortener/cache"
+	"github.com/Conero007/url-shortener/constants"
+	"github.com/Conero007/url-shortener/janitor"
+	"github.com/Conero007/url-shortener/keygen"
+	"github.com/Conero007/url-shortener/keypool"
+	"github.com/Conero007/url-shortener/models"
+	"github.com/Conero007/url-shortener/ratelimit"
+	"github.com/Conero007/url-shortener/reserved"
+	"github.com/Conero007/url-shortener/storage"
+	"github.com/Conero007/url-shortener/urlnorm"
+	"github.com/Conero007/url-shortener/urlsafety"
 	"github.com/gorilla/mux"
-	"github.com/redis/go-redis/v9"
 )
 
 var App *AppConfig
 
+var keyAlphabets = map[string]string{
+	"base62":      constants.BASE_62_CHARACTERS,
+	"unambiguous": constants.UNAMBIGUOUS_CHARACTERS,
+}
+
 type AppConfig struct {
 	Router *mux.Router
-	DB     *sql.DB
-	Redis  *redis.Client
+	Store  models.Store
+	Cache  cache.Cache
+	Clicks *analytics.Recorder
 
-	wg    *sync.WaitGroup
-	debug bool
+	countries      analytics.CountryResolver
+	proxyCountries analytics.CountryResolver
+	counter        analytics.Counter
+	counterFlusher *analytics.CounterFlusher
+	janitor        *janitor.Sweeper
+	requireAPIKey  bool
+	metricsToken   string
+	trustProxy     bool
+	trustedProxies []netip.Prefix
+	maxLinkTTL     time.Duration
+	batchMaxItems  int
+	redirectType   int
+	redirectMaxAge time.Duration
+	dedupeLinks    bool
+	idempotencyTTL time.Duration
+	normalizer     *urlnorm.Normalizer
+	urlSafety      *urlsafety.Checker
+	keys           *keygen.Generator
+	keyPool        *keypool.Pool
+	customKeyMin   int
+	customKeyMax   int
+	reserved       *reserved.Registry
+	cacheTTL       time.Duration
+	limiter        ratelimit.Limiter
+	rateLimits     map[string]ratelimit.Rate
+	wg             *sync.WaitGroup
+	debug          bool
 }
 
 func NewApp(debug bool) *AppConfig {
+	keys, _ := keygen.New(constants.DEFAULT_KEY_GENERATOR, constants.BASE_62_CHARACTERS, constants.DEFAULT_SHORT_KEY_LENGTH, 0, "", nil)
+
 	App = &AppConfig{
-		debug: debug,
-		wg:    &sync.WaitGroup{},
+		debug:          debug,
+		wg:             &sync.WaitGroup{},
+		maxLinkTTL:     constants.DEFAULT_MAX_LINK_TTL,
+		cacheTTL:       constants.DEFAULT_CACHE_TTL,
+		batchMaxItems:  constants.DEFAULT_BATCH_MAX_ITEMS,
+		redirectType:   constants.DEFAULT_REDIRECT_TYPE,
+		redirectMaxAge: constants.DEFAULT_PERMANENT_REDIRECT_MAX_AGE,
+		idempotencyTTL: constants.DEFAULT_IDEMPOTENCY_KEY_TTL,
+		normalizer:     urlnorm.New(nil),
+		urlSafety:      urlsafety.New(strings.Split(constants.DEFAULT_URL_SCHEMES, ","), nil, nil, nil, nil),
+		keys:           keys,
+		customKeyMin:   constants.DEFAULT_CUSTOM_KEY_MIN_LENGTH,
+		customKeyMax:   constants.DEFAULT_CUSTOM_KEY_MAX_LENGTH,
+		reserved:       reserved.New(nil, nil, nil, true),
 	}
+	keys.SetFilter(App.allowedKey)
 	return App
 }
 
-func (a *AppConfig) InitializeDB(addr, user, password, DBName string) error {
-	cfg := mysql.Config{
-		User:      user,
-		Passwd:    password,
-		Net:       "tcp",
-		Addr:      addr,
-		ParseTime: true,
+func (a *AppConfig) InitializeDB(driver, addr, user, password, DBName string) error {
+	if driver == "memory" {
+		a.Store = storage.NewMemoryStore()
+		return nil
+	}
+
+	if driver == "" {
+		driver = "mysql"
 	}
 
-	var err error
-	a.DB, err = sql.Open("mysql", cfg.FormatDSN())
+	store, err := storage.NewSQLStore(driver, addr, user, password, DBName)
 	if err != nil {
 		return err
 	}
 
-	pingErr := a.DB.Ping()
-	if pingErr != nil {
+	if err := store.Migrate(); err != nil {
 		return err
 	}
 
-	if err := database.RunMigrations(App.DB, DBName); err != nil {
+	a.Store = store
+	return nil
+}
+
+// InitializeAuth sets whether API keys are required and whether the client
+// IP and country headers are read from requests sent by trustedProxies, a
+// list of IPs and CIDRs.
+func (a *AppConfig) InitializeAuth(requireAPIKey, trustProxy bool, trustedProxies []string) error {
+	var prefixes []netip.Prefix
+	for _, proxy := range trustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	if trustProxy && len(prefixes) == 0 {
+		return errors.New("trusting proxy headers needs at least one trusted proxy")
+	}
+
+	a.requireAPIKey = requireAPIKey
+	a.trustProxy = trustProxy
+	a.trustedProxies = prefixes
+	return nil
+}
+
+func (a *AppConfig) InitializeLinkExpiry(maxTTL time.Duration) {
+	a.maxLinkTTL = maxTTL
+}
+
+func (a *AppConfig) InitializeURLNormalization(stripParams []string) {
+	a.normalizer = urlnorm.New(stripParams)
+}
+
+// InitializeURLSafety sets which URLs links may point to. selfHosts are the
+// hosts short URLs are served from, so links cannot redirect to themselves.
+func (a *AppConfig) InitializeURLSafety(schemes, blockedDomains, allowedDomains, shortenerDomains, selfHosts []string) {
+	a.urlSafety = urlsafety.New(schemes, blockedDomains, allowedDomains, shortenerDomains, selfHosts)
+}
+
+// InitializeKeyGenerator sets how generated keys are made. alphabet names one
+// of keyAlphabets, and the key length grows once growthThreshold percent of
+// the keys of the current length are in use. The counter strategy needs a
+// secret, since without one anybody can recover the numbers behind its keys.
+func (a *AppConfig) InitializeKeyGenerator(strategy, alphabet string, length, growthThreshold int, secret string) error {
+	characters, ok := keyAlphabets[alphabet]
+	if !ok {
+		return fmt.Errorf("unsupported key alphabet %q, expected 'base62' or 'unambiguous'", alphabet)
+	}
+	if strategy == keygen.StrategyCounter && secret == "" {
+		return errors.New("the counter key generator requires a secret")
+	}
+
+	var sequence models.KeySequenceStore
+	if a.Store != nil {
+		sequence = a.Store
+	}
+	keys, err := keygen.New(strategy, characters, length, growthThreshold, secret, sequence)
+	if err != nil {
 		return err
 	}
+	keys.SetFilter(a.allowedKey)
 
-	return err
+	if a.keys != nil {
+		a.keys.Close()
+	}
+	a.keys = keys
+	if a.Store != nil {
+		a.keys.Start(a.Store, constants.KEY_LENGTH_CHECK_INTERVAL)
+	}
+	return nil
+}
+
+func (a *AppConfig) InitializeCustomKeys(minLength, maxLength int) error {
+	if minLength < 1 || minLength > maxLength || maxLength > constants.MAX_SHORT_KEY_LENGTH {
+		return fmt.Errorf("custom key lengths must satisfy 1 <= min <= max <= %d", constants.MAX_SHORT_KEY_LENGTH)
+	}
+	a.customKeyMin, a.customKeyMax = minLength, maxLength
+	return nil
+}
+
+// InitializeReservedKeys sets the keys links may not use on top of the route
+// names, the words no key may contain and the words that contain a blocked
+// word but are fine.
+func (a *AppConfig) InitializeReservedKeys(keys, blockedWords, allowedWords []string, profanityFilter bool) {
+	a.reserved = reserved.New(keys, blockedWords, allowedWords, profanityFilter)
+}
+
+// allowedKey filters generated keys through the reserved keys in use at the
+// time, so it keeps working after InitializeReservedKeys.
+func (a *AppConfig) allowedKey(key string) bool {
+	return a.reserved.Allowed(key)
+}
+
+// InitializeKeyPool replaces the key pool, which is disabled when size is 0.
+func (a *AppConfig) InitializeKeyPool(size, lowWatermark, batchSize int, interval time.Duration) error {
+	if size > 0 && (batchSize <= 0 || interval <= 0) {
+		return errors.New("refill batch size and interval must be positive")
+	}
+
+	if a.keyPool != nil {
+		a.keyPool.Close()
+		a.keyPool = nil
+	}
+
+	if size > 0 {
+		a.keyPool = keypool.NewPool(a.Store, a.keys, size, lowWatermark, batchSize, interval)
+	}
+	return nil
+}
+
+// keyGenerator returns where generated keys come from, the key pool when it
+// is enabled.
+func (a *AppConfig) keyGenerator() models.KeyGenerator {
+	if a.keyPool != nil {
+		return a.keyPool
+	}
+	return a.keys
 }
 
 func (a *AppConfig) InitializeRoutes() {
 	a.Router = mux.NewRouter()
-	App.Router.HandleFunc("/shorten", HandleURLShortening).Methods(http.MethodPost)
-	App.Router.HandleFunc("/{key}", HandleRedirectToOriginalURL).Methods(http.MethodGet)
+	a.Router.Handle("/metrics", authenticateMetrics(http.HandlerFunc(HandleMetrics))).Methods(http.MethodGet)
+
+	authenticated := a.Router.NewRoute().Subrouter()
+	authenticated.Use(authenticateAPIKey, rateLimit("shorten"))
+	authenticated.HandleFunc("/shorten", idempotent(HandleURLShortening)).Methods(http.MethodPost)
+	authenticated.HandleFunc("/shorten/batch", limitBatchBody(idempotent(HandleBatchURLShortening))).Methods(http.MethodPost)
+	authenticated.HandleFunc("/api/links", HandleListLinks).Methods(http.MethodGet)
+	authenticated.HandleFunc("/api/links/import", HandleImportLinks).Methods(http.MethodPost)
+	authenticated.HandleFunc("/api/links/export", HandleExportLinks).Methods(http.MethodGet)
+	authenticated.HandleFunc("/api/links/{key}", HandleGetLink).Methods(http.MethodGet)
+	authenticated.HandleFunc("/api/links/{key}", HandleUpdateLink).Methods(http.MethodPatch)
+	authenticated.HandleFunc("/api/links/{key}", HandleDeleteLink).Methods(http.MethodDelete)
+	authenticated.HandleFunc("/{key}/stats", HandleShortURLStats).Methods(http.MethodGet)
+
+	public := a.Router.NewRoute().Subrouter()
+	public.Use(rateLimit("redirect"))
+	public.HandleFunc("/{key}", HandleRedirectToOriginalURL).Methods(http.MethodGet)
 }
 
-func (a *AppConfig) InitializeRedis(addr, password string) error {
-	a.Redis = redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: "",
-		DB:       0,
-	})
+func (a *AppConfig) InitializeCache(driver, addr, password string, size int, ttl time.Duration) error {
+	a.cacheTTL = ttl
 
-	_, err := a.Redis.Ping(context.Background()).Result()
-	return err
+	switch driver {
+	case "", "redis":
+		redisCache, err := cache.NewRedisCache(addr, password)
+		if err != nil {
+			log.Println("[Error] Could not connect to redis, falling back to in-memory cache ", err)
+			a.Cache = cache.NewLRUCache(size, ttl)
+			return nil
+		}
+		a.Cache = redisCache
+	case "memory":
+		a.Cache = cache.NewLRUCache(size, ttl)
+	default:
+		return fmt.Errorf("unsupported cache driver %q", driver)
+	}
+	return nil
 }
 
 func (a *AppConfig) Run(addr string) error {
-	log.Printf("Starting Server at http://%s\n", addr)
-	if err := http.ListenAndServe(addr, a.Router); err != nil {
-		return err
+	server := &http.Server{Addr: addr, Handler: a.Router}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting Server at http://%s\n", addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+	a.Shutdown()
+
+	return err
+}
+
+func (a *AppConfig) Shutdown() {
+	a.wg.Wait()
+
+	if a.Clicks != nil {
+		a.Clicks.Close()
+	}
+
+	if a.counterFlusher != nil {
+		a.counterFlusher.Close()
+	}
+
+	if a.janitor != nil {
+		a.janitor.Close()
+	}
+
+	if a.keyPool != nil {
+		a.keyPool.Close()
+	}
+
+	a.keys.Close()
+
+	if err := a.Cache.Close(); err != nil {
+		log.Println("[Error] Could not close cache ", err)
+	}
+
+	if err := a.Store.Close(); err != nil {
+		log.Println("[Error] Could not close store ", err)
 	}
-	return nil
 }
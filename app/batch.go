@@ -0,0 +1,262 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Conero007/url-shortener/constants"
+	"github.com/Conero007/url-shortener/models"
+)
+
+type BatchItemResult struct {
+	Index       int        `json:"index"`
+	Status      int        `json:"status"`
+	OriginalURL string     `json:"original_url,omitempty"`
+	ShortURL    string     `json:"short_url,omitempty"`
+	ExpireTime  *time.Time `json:"expire_time,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Code        string     `json:"code,omitempty"`
+}
+
+type BatchResponse struct {
+	Created  int               `json:"created"`
+	Existing int               `json:"existing"`
+	Failed   int               `json:"failed"`
+	Results  []BatchItemResult `json:"results"`
+}
+
+func (a *AppConfig) InitializeBatchShortening(maxItems int) {
+	a.batchMaxItems = maxItems
+}
+
+// limitBatchBody caps the request body at what BATCH_MAX_ITEMS items can take.
+func limitBatchBody(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(App.batchMaxItems+1)*constants.MAX_BATCH_ITEM_BYTES)
+		next(w, r)
+	}
+}
+
+// HandleBatchURLShortening accepts either a JSON array of shorten requests or
+// one request per line (NDJSON) and creates all valid links in a single
+// transaction, reporting the outcome of every item.
+func HandleBatchURLShortening(w http.ResponseWriter, r *http.Request) {
+	items, err := decodeBatchRequest(r.Body, App.batchMaxItems)
+	if errors.As(err, new(*http.MaxBytesError)) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if len(items) == 0 {
+		respondWithError(w, http.StatusBadRequest, "No items given")
+		return
+	} else if len(items) > App.batchMaxItems {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("At most %d items can be given", App.batchMaxItems))
+		return
+	}
+
+	reqs := make([]*ShortenURLRequest, len(items))
+	for i, item := range items {
+		var req ShortenURLRequest
+		if json.Unmarshal(item, &req) == nil {
+			reqs[i] = &req
+		}
+	}
+
+	results, err := createShortURLs(reqs, apiKeyFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+		return
+	}
+
+	response := BatchResponse{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case http.StatusCreated:
+			response.Created++
+		case http.StatusOK:
+			response.Existing++
+		default:
+			response.Failed++
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// createShortURLs validates and inserts the given requests in one batch. A nil
+// request stands for an item that could not be decoded.
+func createShortURLs(reqs []*ShortenURLRequest, apiKey *models.APIKey) ([]BatchItemResult, error) {
+	results := make([]BatchItemResult, len(reqs))
+	urls := make([]*models.ShortenURL, len(reqs))
+	// duplicates maps an item to the earlier item of the batch whose link it
+	// reuses, and created each normalized URL to the items generating a key
+	// for it.
+	duplicates := make(map[int]int)
+	created := make(map[string][]int)
+
+	for i, req := range reqs {
+		results[i] = BatchItemResult{Index: i, Status: http.StatusBadRequest, Error: "Invalid request payload"}
+		if req == nil {
+			continue
+		}
+
+		u, reqErr := prepareShortenURL(req, apiKey)
+		if reqErr != nil {
+			results[i].Status, results[i].Code, results[i].Error = reqErr.status, reqErr.code, reqErr.message
+			continue
+		}
+
+		existing, err := findDuplicateLink(req, u)
+		if err != nil {
+			return nil, err
+		} else if existing != nil {
+			results[i] = newBatchItemResult(i, http.StatusOK, existing)
+			continue
+		}
+
+		if first, ok := findDuplicateItem(req, u, urls, created[u.NormalizedURL]); ok {
+			duplicates[i] = first
+			continue
+		} else if req.CustomShortKey == "" {
+			created[u.NormalizedURL] = append(created[u.NormalizedURL], i)
+		}
+		urls[i] = u
+	}
+
+	errs, err := models.CreateShortURLs(App.Store, urls, App.keyGenerator())
+	if err != nil {
+		log.Println("[Error] Could not insert batch ", err)
+		return nil, err
+	}
+
+	for i, err := range errs {
+		if errors.Is(err, models.ErrShortKeyExists) {
+			results[i].Status, results[i].Code, results[i].Error = errShortKeyTaken.status, errShortKeyTaken.code, errShortKeyTaken.message
+			urls[i] = nil
+		} else if errors.Is(err, models.ErrNoShortKeyLeft) {
+			results[i].Status, results[i].Code, results[i].Error = errNoShortKeyLeft.status, errNoShortKeyLeft.code, errNoShortKeyLeft.message
+			urls[i] = nil
+		} else if err != nil {
+			log.Println("[Error] Could not insert short url in batch ", err)
+			results[i].Status, results[i].Error = http.StatusInternalServerError, "Something went wrong. Please try again."
+			urls[i] = nil
+		}
+	}
+
+	for i, u := range urls {
+		if u == nil {
+			continue
+		}
+
+		results[i] = newBatchItemResult(i, http.StatusCreated, u)
+
+		App.wg.Add(1)
+		go setCacheKey(App.Cache, context.Background(), App.wg, u.ShortKey, u, linkCacheTTL(u))
+	}
+
+	for i, first := range duplicates {
+		if u := urls[first]; u != nil {
+			results[i] = newBatchItemResult(i, http.StatusOK, u)
+		} else {
+			results[i] = results[first]
+			results[i].Index = i
+		}
+	}
+
+	return results, nil
+}
+
+// findDuplicateItem returns the first of the given earlier items whose link
+// the request can reuse.
+func findDuplicateItem(req *ShortenURLRequest, u *models.ShortenURL, urls []*models.ShortenURL, items []int) (int, bool) {
+	if !dedupeRequested(req) {
+		return 0, false
+	}
+	for _, i := range items {
+		if matchesLink(req, u, urls[i]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func newBatchItemResult(index, status int, u *models.ShortenURL) BatchItemResult {
+	return BatchItemResult{
+		Index:       index,
+		Status:      status,
+		OriginalURL: u.OriginalURL,
+		ShortURL:    u.ShortURL,
+		ExpireTime:  u.ExpireTime,
+	}
+}
+
+// decodeBatchRequest reads the items of a batch, stopping once more than
+// maxItems have been read.
+func decodeBatchRequest(body io.Reader, maxItems int) ([]json.RawMessage, error) {
+	reader := bufio.NewReader(body)
+
+	for {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		reader.UnreadByte()
+
+		if c == '[' {
+			return decodeBatchArray(json.NewDecoder(reader), maxItems)
+		}
+		break
+	}
+
+	var items []json.RawMessage
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for len(items) <= maxItems && scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			items = append(items, json.RawMessage(append([]byte(nil), line...)))
+		}
+	}
+	return items, scanner.Err()
+}
+
+func decodeBatchArray(decoder *json.Decoder, maxItems int) ([]json.RawMessage, error) {
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	var items []json.RawMessage
+	for decoder.More() {
+		if len(items) > maxItems {
+			return items, nil
+		}
+
+		var item json.RawMessage
+		if err := decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
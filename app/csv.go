@@ -0,0 +1,251 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+const (
+	importChunkSize = 100
+	exportPageSize  = 500
+)
+
+var exportColumns = []string{"short_key", "url", "short_url", "tags", "click_count", "created_at", "expire_time", "disabled", "archived_at"}
+
+type ImportError struct {
+	Line   int    `json:"line"`
+	Status int    `json:"status"`
+	Code   string `json:"code,omitempty"`
+	Error  string `json:"error"`
+}
+
+type ImportResult struct {
+	Created  int           `json:"created"`
+	Existing int           `json:"existing"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors"`
+}
+
+func HandleImportLinks(w http.ResponseWriter, r *http.Request) {
+	apiKey := apiKeyFromContext(r.Context())
+	if apiKey == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener"`)
+		respondWithError(w, http.StatusUnauthorized, "API key required")
+		return
+	}
+
+	result, err := App.ImportLinksCSV(r.Body, apiKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// ImportLinksCSV creates a link for every url,custom_short_key,expire_time row
+// of the CSV, reading and inserting it in chunks. A leading header row is
+// skipped.
+func (a *AppConfig) ImportLinksCSV(r io.Reader, apiKey *models.APIKey) (*ImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	result := &ImportResult{Errors: []ImportError{}}
+	var reqs []*ShortenURLRequest
+	var lines []int
+
+	flush := func() error {
+		if len(reqs) == 0 {
+			return nil
+		}
+		results, err := createShortURLs(reqs, apiKey)
+		if err != nil {
+			return err
+		}
+		for i, res := range results {
+			if res.Status == http.StatusCreated {
+				result.Created++
+				continue
+			} else if res.Status == http.StatusOK {
+				result.Existing++
+				continue
+			}
+			result.Failed++
+			result.Errors = append(result.Errors, ImportError{Line: lines[i], Status: res.Status, Code: res.Code, Error: res.Error})
+		}
+		reqs, lines = reqs[:0], lines[:0]
+		return nil
+	}
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			result.Failed++
+			result.Errors = append(result.Errors, ImportError{Line: parseErr.StartLine, Status: http.StatusBadRequest, Error: "Invalid CSV row"})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "url") {
+			continue
+		}
+
+		req, err := parseImportRecord(record)
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, ImportError{Line: line, Status: http.StatusBadRequest, Error: err.Error()})
+			continue
+		}
+
+		reqs = append(reqs, req)
+		lines = append(lines, line)
+		if len(reqs) == importChunkSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+	return result, nil
+}
+
+func parseImportRecord(record []string) (*ShortenURLRequest, error) {
+	if len(record) > 3 {
+		return nil, errors.New("Expected at most 3 columns: url,custom_short_key,expire_time")
+	}
+
+	fields := make([]string, 3)
+	for i, field := range record {
+		fields[i] = strings.TrimSpace(field)
+	}
+
+	req := &ShortenURLRequest{URL: fields[0], CustomShortKey: fields[1]}
+	if fields[2] != "" {
+		expireTime, ok := parseTimeParam(fields[2])
+		if !ok {
+			return nil, errors.New("Invalid expire_time")
+		}
+		req.ExpireTime = &expireTime
+	}
+	return req, nil
+}
+
+func HandleExportLinks(w http.ResponseWriter, r *http.Request) {
+	apiKey := apiKeyFromContext(r.Context())
+	if apiKey == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener"`)
+		respondWithError(w, http.StatusUnauthorized, "API key required")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="links.csv"`)
+		format = "csv"
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="links.ndjson"`)
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid format, expected 'csv' or 'ndjson'")
+		return
+	}
+
+	if err := App.ExportLinks(w, apiKey, format); err != nil {
+		log.Println("[Error] Could not export links ", err)
+	}
+}
+
+// ExportLinks writes every link owned by the API key as CSV or NDJSON, one
+// page of links at a time.
+func (a *AppConfig) ExportLinks(w io.Writer, apiKey *models.APIKey, format string) error {
+	csvWriter := csv.NewWriter(w)
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	if format == "csv" {
+		if err := csvWriter.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+
+	filter := models.LinkFilter{APIKeyID: apiKey.ID, SortBy: models.LinkSortCreatedAt, Ascending: true, Limit: exportPageSize}
+	for {
+		urls, err := a.Store.ListShortURLs(filter)
+		if err != nil {
+			return err
+		}
+
+		for i := range urls {
+			urls[i].GenerateShortURL()
+			link := newLink(&urls[i])
+			if format == "csv" {
+				err = csvWriter.Write(exportRecord(link))
+			} else {
+				err = encoder.Encode(link)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(urls) < filter.Limit {
+			return nil
+		}
+		cursor := urls[len(urls)-1].Cursor()
+		filter.After = &cursor
+	}
+}
+
+func exportRecord(l Link) []string {
+	return []string{
+		l.ShortKey,
+		l.OriginalURL,
+		l.ShortURL,
+		strings.Join(l.Tags, ";"),
+		strconv.FormatInt(l.ClickCount, 10),
+		l.CreatedAt.UTC().Format(time.RFC3339),
+		formatOptionalTime(l.ExpireTime),
+		strconv.FormatBool(l.Disabled),
+		formatOptionalTime(l.ArchivedAt),
+	}
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
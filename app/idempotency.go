@@ -0,0 +1,124 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/constants"
+)
+
+// idempotentResponse is the response stored for an Idempotency-Key, replayed
+// when a client retries the same request.
+type idempotentResponse struct {
+	RequestHash string `json:"request_hash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+var inFlightIdempotencyKeys sync.Map
+
+func (a *AppConfig) InitializeDeduplication(dedupe bool, idempotencyTTL time.Duration) {
+	a.dedupeLinks = dedupe
+	a.idempotencyTTL = idempotencyTTL
+}
+
+// idempotent replays the stored response of a request carrying an
+// Idempotency-Key that was already handled, so clients can safely retry.
+// Keys are scoped to the API key, or the client IP for anonymous requests.
+func idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		if idempotencyKey == "" {
+			next(w, r)
+			return
+		}
+
+		if len(idempotencyKey) > constants.MAX_IDEMPOTENCY_KEY_LENGTH {
+			respondWithError(w, http.StatusBadRequest, "Invalid Idempotency-Key")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if errors.As(err, new(*http.MaxBytesError)) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(hash[:])
+
+		cacheKey := "idempotency:ip:" + getClientIP(r) + ":" + idempotencyKey
+		if apiKey := apiKeyFromContext(r.Context()); apiKey != nil {
+			cacheKey = "idempotency:key:" + strconv.FormatInt(apiKey.ID, 10) + ":" + idempotencyKey
+		}
+
+		if _, busy := inFlightIdempotencyKeys.LoadOrStore(cacheKey, true); busy {
+			respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+			return
+		}
+		defer inFlightIdempotencyKeys.Delete(cacheKey)
+
+		var stored idempotentResponse
+		if err := getCacheKey(App.Cache, context.Background(), cacheKey, &stored); err == nil {
+			if stored.RequestHash != requestHash {
+				respondWithError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				return
+			}
+
+			w.Header().Set("Content-Type", stored.ContentType)
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next(recorder, r)
+
+		// Server errors are not stored so that a retry gets another chance.
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		stored = idempotentResponse{
+			RequestHash: requestHash,
+			Status:      recorder.status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+		App.wg.Add(1)
+		setCacheKey(App.Cache, context.Background(), App.wg, cacheKey, stored, App.idempotencyTTL)
+	}
+}
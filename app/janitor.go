@@ -0,0 +1,23 @@
+package app
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Conero007/url-shortener/janitor"
+)
+
+func (a *AppConfig) InitializeJanitor(interval, quarantine time.Duration, batchSize int) error {
+	if interval <= 0 {
+		return errors.New("sweep interval must be positive")
+	}
+	a.janitor = janitor.NewSweeper(a.Store, a.Cache, interval, quarantine, batchSize)
+	return nil
+}
+
+func (a *AppConfig) SweepExpiredURLs() (archived, purged int, err error) {
+	if a.janitor == nil {
+		return 0, 0, nil
+	}
+	return a.janitor.Sweep()
+}
@@ -0,0 +1,306 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Conero007/url-shortener/constants"
+	"github.com/Conero007/url-shortener/models"
+	"github.com/Conero007/url-shortener/urlnorm"
+	"github.com/gorilla/mux"
+)
+
+type Link struct {
+	ShortKey     string     `json:"short_key"`
+	OriginalURL  string     `json:"original_url"`
+	ShortURL     string     `json:"short_url"`
+	Tags         []string   `json:"tags"`
+	RedirectType int        `json:"redirect_type"`
+	ClickCount   int64      `json:"click_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpireTime   *time.Time `json:"expire_time"`
+	Disabled     bool       `json:"disabled"`
+	Expired      bool       `json:"expired"`
+	ArchivedAt   *time.Time `json:"archived_at"`
+}
+
+type LinkList struct {
+	Links      []Link `json:"links"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type UpdateLinkRequest struct {
+	URL          *string   `json:"url"`
+	Disabled     *bool     `json:"disabled"`
+	Tags         *[]string `json:"tags"`
+	RedirectType *int      `json:"redirect_type"`
+	ExpiryRequest
+}
+
+func newLink(u *models.ShortenURL) Link {
+	tags := u.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return Link{
+		ShortKey:     u.ShortKey,
+		OriginalURL:  u.OriginalURL,
+		ShortURL:     u.ShortURL,
+		Tags:         tags,
+		RedirectType: linkRedirectType(u),
+		ClickCount:   u.ClickCount,
+		CreatedAt:    u.CreatedAt,
+		ExpireTime:   u.ExpireTime,
+		Disabled:     u.Disabled,
+		Expired:      u.Expired(),
+		ArchivedAt:   u.ArchivedAt,
+	}
+}
+
+func HandleListLinks(w http.ResponseWriter, r *http.Request) {
+	apiKey := apiKeyFromContext(r.Context())
+	if apiKey == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener"`)
+		respondWithError(w, http.StatusUnauthorized, "API key required")
+		return
+	}
+
+	query := r.URL.Query()
+	domain, err := urlnorm.NormalizeHost(query.Get("domain"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid domain")
+		return
+	}
+
+	filter := models.LinkFilter{
+		APIKeyID: apiKey.ID,
+		Domain:   domain,
+		Tag:      strings.ToLower(query.Get("tag")),
+		Status:   query.Get("status"),
+		SortBy:   strings.TrimPrefix(query.Get("sort"), "-"),
+		Limit:    constants.DEFAULT_LINKS_PAGE_SIZE,
+	}
+
+	switch filter.Status {
+	case "", models.LinkStatusActive, models.LinkStatusExpired:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid status, expected 'active' or 'expired'")
+		return
+	}
+
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = models.LinkSortCreatedAt
+	case models.LinkSortCreatedAt, models.LinkSortClickCount:
+		filter.Ascending = !strings.HasPrefix(query.Get("sort"), "-")
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid sort, expected 'created_at' or 'click_count'")
+		return
+	}
+
+	for param, dest := range map[string]*time.Time{"created_from": &filter.CreatedFrom, "created_to": &filter.CreatedTo} {
+		if val := query.Get(param); val != "" {
+			var ok bool
+			if *dest, ok = parseTimeParam(val); !ok {
+				respondWithError(w, http.StatusBadRequest, "Invalid '"+param+"' time")
+				return
+			}
+		}
+	}
+
+	if val := query.Get("limit"); val != "" {
+		limit, err := strconv.Atoi(val)
+		if err != nil || limit < 1 || limit > constants.MAX_LINKS_PAGE_SIZE {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit, expected 1 to %d", constants.MAX_LINKS_PAGE_SIZE))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if val := query.Get("cursor"); val != "" {
+		cursor, err := decodeLinkCursor(val)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		filter.After = cursor
+	}
+
+	pageSize := filter.Limit
+	filter.Limit++
+
+	urls, err := App.Store.ListShortURLs(filter)
+	if err != nil {
+		log.Println("[Error] Could not list short urls ", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+		return
+	}
+
+	response := LinkList{Links: make([]Link, 0, len(urls))}
+	if len(urls) > pageSize {
+		urls = urls[:pageSize]
+		response.NextCursor = encodeLinkCursor(urls[pageSize-1].Cursor())
+	}
+	for i := range urls {
+		urls[i].GenerateShortURL()
+		response.Links = append(response.Links, newLink(&urls[i]))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func HandleGetLink(w http.ResponseWriter, r *http.Request) {
+	u, ok := fetchOwnedLink(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newLink(u))
+}
+
+func HandleUpdateLink(w http.ResponseWriter, r *http.Request) {
+	var requestBody UpdateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	u, ok := fetchOwnedLink(w, r)
+	if !ok {
+		return
+	}
+
+	if requestBody.URL != nil {
+		normalizedURL, reqErr := checkDestination(*requestBody.URL)
+		if reqErr != nil {
+			respondWithRequestError(w, reqErr)
+			return
+		}
+		u.OriginalURL, u.NormalizedURL = *requestBody.URL, normalizedURL
+	}
+
+	if requestBody.ExpiryRequest != (ExpiryRequest{}) {
+		expireTime, err := resolveExpireTime(&requestBody.ExpiryRequest, apiKeyFromContext(r.Context()))
+		if errors.Is(err, errNoExpiryForbidden) {
+			respondWithError(w, http.StatusForbidden, err.Error())
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		u.ExpireTime = expireTime
+		u.ArchivedAt = nil
+	}
+
+	if requestBody.Disabled != nil {
+		u.Disabled = *requestBody.Disabled
+	}
+
+	if requestBody.RedirectType != nil {
+		if !validRedirectType(*requestBody.RedirectType) {
+			respondWithError(w, http.StatusBadRequest, "Invalid redirect_type, expected 301, 302, 307 or 308")
+			return
+		}
+		u.RedirectType = *requestBody.RedirectType
+	}
+
+	if requestBody.Tags != nil {
+		tags, err := models.NormalizeTags(*requestBody.Tags)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		u.Tags = tags
+	}
+
+	if !saveLink(w, u) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newLink(u))
+}
+
+func HandleDeleteLink(w http.ResponseWriter, r *http.Request) {
+	u, ok := fetchOwnedLink(w, r)
+	if !ok {
+		return
+	}
+
+	if u.ArchivedAt == nil {
+		now := time.Now().UTC()
+		u.ArchivedAt = &now
+		if !saveLink(w, u) {
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fetchOwnedLink loads the link named in the URL, responding with 404 when it
+// does not exist or was created with a different API key.
+func fetchOwnedLink(w http.ResponseWriter, r *http.Request) (*models.ShortenURL, bool) {
+	key := mux.Vars(r)["key"]
+
+	if !validateShortKey(key) {
+		respondWithError(w, http.StatusBadRequest, "Invalid short key")
+		return nil, false
+	}
+
+	apiKey := apiKeyFromContext(r.Context())
+	if apiKey == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener"`)
+		respondWithError(w, http.StatusUnauthorized, "API key required")
+		return nil, false
+	}
+
+	u := models.ShortenURL{ShortKey: key}
+	err := u.FetchShortURLData(App.Store)
+	if errors.Is(err, models.ErrShortURLNotFound) || (err == nil && u.APIKeyID != apiKey.ID) {
+		respondWithError(w, http.StatusNotFound, "Short Key not found")
+		return nil, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+		return nil, false
+	}
+
+	return &u, true
+}
+
+func saveLink(w http.ResponseWriter, u *models.ShortenURL) bool {
+	if err := u.UpdateShortURLData(App.Store); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+		return false
+	}
+
+	App.wg.Add(1)
+	deleteCacheKey(App.Cache, context.Background(), App.wg, u.ShortKey)
+
+	return true
+}
+
+func encodeLinkCursor(c models.LinkCursor) string {
+	val, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(val)
+}
+
+func decodeLinkCursor(val string) (*models.LinkCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(val)
+	if err != nil {
+		return nil, err
+	}
+
+	var c models.LinkCursor
+	if err := json.Unmarshal(decoded, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
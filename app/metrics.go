@@ -0,0 +1,37 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/Conero007/url-shortener/janitor"
+	"github.com/Conero007/url-shortener/keygen"
+	"github.com/Conero007/url-shortener/keypool"
+)
+
+type MetricsResponse struct {
+	Janitor      *janitor.Metrics `json:"janitor,omitempty"`
+	KeyGenerator keygen.Stats     `json:"key_generator"`
+	KeyPool      *keypool.Metrics `json:"key_pool,omitempty"`
+}
+
+// InitializeMetrics sets the token GET /metrics requires. An empty token
+// turns the endpoint off.
+func (a *AppConfig) InitializeMetrics(token string) {
+	a.metricsToken = token
+}
+
+func HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	response := MetricsResponse{KeyGenerator: App.keys.Stats()}
+
+	if App.janitor != nil {
+		metrics := App.janitor.Metrics()
+		response.Janitor = &metrics
+	}
+
+	if App.keyPool != nil {
+		metrics := App.keyPool.Metrics()
+		response.KeyPool = &metrics
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
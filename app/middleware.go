@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+type contextKey string
+
+const apiKeyContextKey contextKey = "api_key"
+
+func authenticateAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := bearerToken(r)
+		if !ok {
+			if !App.requireAPIKey {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener"`)
+			respondWithError(w, http.StatusUnauthorized, "API key required")
+			return
+		}
+
+		apiKey, err := App.Store.FetchAPIKeyByHash(models.HashAPIKey(key))
+		if err != nil && !errors.Is(err, models.ErrAPIKeyNotFound) {
+			respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+			return
+		}
+
+		if apiKey == nil || !apiKey.Active() {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener", error="invalid_token"`)
+			respondWithError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyContextKey, apiKey)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateMetrics lets only requests bearing the metrics token through.
+// Without a token configured the metrics are not served at all.
+func authenticateMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if App.metricsToken == "" {
+			respondWithError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(App.metricsToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener"`)
+			respondWithError(w, http.StatusUnauthorized, "Metrics token required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func apiKeyFromContext(ctx context.Context) *models.APIKey {
+	apiKey, _ := ctx.Value(apiKeyContextKey).(*models.APIKey)
+	return apiKey
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Conero007/url-shortener/cache"
+	"github.com/Conero007/url-shortener/ratelimit"
+	"github.com/gorilla/mux"
+)
+
+func (a *AppConfig) InitializeRateLimiting(shortenRate, redirectRate ratelimit.Rate) {
+	memoryLimiter := ratelimit.NewMemoryLimiter()
+
+	if redisCache, ok := a.Cache.(*cache.RedisCache); ok {
+		a.limiter = ratelimit.FallbackLimiter{
+			Primary:  ratelimit.NewRedisLimiter(redisCache.Client),
+			Fallback: memoryLimiter,
+		}
+	} else {
+		a.limiter = memoryLimiter
+	}
+
+	a.rateLimits = map[string]ratelimit.Rate{
+		"shorten":  shortenRate,
+		"redirect": redirectRate,
+	}
+}
+
+func rateLimit(scope string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rate, ok := App.rateLimits[scope]
+			if App.limiter == nil || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "ratelimit:" + scope + ":ip:" + getClientIP(r)
+			if apiKey := apiKeyFromContext(r.Context()); apiKey != nil {
+				key = "ratelimit:" + scope + ":key:" + strconv.FormatInt(apiKey.ID, 10)
+			}
+
+			res, err := App.limiter.Allow(r.Context(), key, rate)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+func (a *AppConfig) InitializeRedirects(defaultType int, permanentMaxAge time.Duration) error {
+	if !validRedirectType(defaultType) {
+		return fmt.Errorf("unsupported redirect type %d", defaultType)
+	}
+	a.redirectType = defaultType
+	a.redirectMaxAge = permanentMaxAge
+	return nil
+}
+
+func validRedirectType(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+func linkRedirectType(u *models.ShortenURL) int {
+	if u.RedirectType == 0 {
+		return App.redirectType
+	}
+	return u.RedirectType
+}
+
+// redirectCacheControl keeps temporary redirects out of caches so every visit
+// is counted, and lets permanent ones be cached no longer than the link lives.
+func redirectCacheControl(u *models.ShortenURL, status int) string {
+	if status == http.StatusFound || status == http.StatusTemporaryRedirect {
+		return "private, no-store"
+	}
+
+	maxAge := App.redirectMaxAge
+	if u.ExpireTime != nil {
+		if untilExpiry := time.Until(*u.ExpireTime); untilExpiry < maxAge {
+			maxAge = untilExpiry
+		}
+	}
+	return fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+}
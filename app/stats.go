@@ -0,0 +1,78 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Conero007/url-shortener/analytics"
+	"github.com/Conero007/url-shortener/constants"
+)
+
+// HandleShortURLStats reports the clicks of a link to the API key owning it.
+func HandleShortURLStats(w http.ResponseWriter, r *http.Request) {
+	u, ok := fetchOwnedLink(w, r)
+	if !ok {
+		return
+	}
+
+	to := time.Now().UTC()
+	if val := r.URL.Query().Get("to"); val != "" {
+		var ok bool
+		if to, ok = parseTimeParam(val); !ok {
+			respondWithError(w, http.StatusBadRequest, "Invalid 'to' time")
+			return
+		}
+	}
+
+	from := to.Add(-constants.DEFAULT_STATS_RANGE)
+	if val := r.URL.Query().Get("from"); val != "" {
+		var ok bool
+		if from, ok = parseTimeParam(val); !ok {
+			respondWithError(w, http.StatusBadRequest, "Invalid 'from' time")
+			return
+		}
+	}
+
+	if !from.Before(to) {
+		respondWithError(w, http.StatusBadRequest, "'from' must be before 'to'")
+		return
+	}
+
+	if to.Sub(from) > constants.MAX_STATS_RANGE {
+		respondWithError(w, http.StatusBadRequest, "Requested time range is too large")
+		return
+	}
+
+	// Totals and days come from the daily aggregates. Only the most recent
+	// clicks are read one by one, for the hourly clicks and the top values.
+	detailsFrom := from
+	if to.Sub(from) > constants.MAX_STATS_DETAIL_RANGE {
+		detailsFrom = to.Add(-constants.MAX_STATS_DETAIL_RANGE)
+	}
+	builder := analytics.NewStatsBuilder(u.ShortKey, from, to, detailsFrom, constants.STATS_TOP_LIMIT)
+
+	counts, err := App.Store.FetchClickCounts(u.ShortKey, from.Truncate(24*time.Hour), to)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+		return
+	}
+	for _, c := range counts {
+		builder.AddDay(c)
+	}
+
+	if err := App.Store.IterateClicks(u.ShortKey, detailsFrom, to, builder.Add); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, builder.Build())
+}
+
+func parseTimeParam(val string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
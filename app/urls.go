@@ -3,16 +3,58 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"time"
 
+	"github.com/Conero007/url-shortener/config"
+	"github.com/Conero007/url-shortener/constants"
 	"github.com/Conero007/url-shortener/models"
+	"github.com/Conero007/url-shortener/reserved"
+	"github.com/Conero007/url-shortener/urlnorm"
+	"github.com/Conero007/url-shortener/urlsafety"
 	"github.com/gorilla/mux"
 )
 
+var errNoExpiryForbidden = errors.New("API key is not allowed to create links without expiry")
+
+var (
+	errShortKeyTaken  = &requestError{http.StatusNotAcceptable, "custom_key_taken", "Short key not available to use"}
+	errNoShortKeyLeft = &requestError{http.StatusServiceUnavailable, "no_key_available", "No short key available. Please try again later."}
+)
+
+type ExpiryRequest struct {
+	ExpireTime *time.Time `json:"expire_time"`
+	TTL        string     `json:"ttl"`
+	NoExpiry   bool       `json:"no_expiry"`
+}
+
 type ShortenURLRequest struct {
-	URL            string `json:"url"`
-	CustomShortKey string `json:"custom_short_key"`
+	URL            string   `json:"url"`
+	CustomShortKey string   `json:"custom_short_key"`
+	Tags           []string `json:"tags"`
+	RedirectType   int      `json:"redirect_type"`
+	Dedupe         *bool    `json:"dedupe"`
+	ExpiryRequest
+}
+
+// requestError is a validation failure that maps to an HTTP status, with a
+// code clients can match on.
+type requestError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func respondWithRequestError(w http.ResponseWriter, e *requestError) {
+	respondWithJSON(w, e.status, map[string]string{"error": e.message, "code": e.code})
 }
 
 func HandleURLShortening(w http.ResponseWriter, r *http.Request) {
@@ -22,39 +64,196 @@ func HandleURLShortening(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if requestBody.URL == "" {
-		respondWithError(w, http.StatusBadRequest, "URL not given")
+	u, reqErr := prepareShortenURL(&requestBody, apiKeyFromContext(r.Context()))
+	if reqErr != nil {
+		respondWithRequestError(w, reqErr)
 		return
 	}
 
-	if !validateURL(requestBody.URL) {
-		respondWithError(w, http.StatusBadRequest, "Invalid URL given")
+	existing, err := findDuplicateLink(&requestBody, u)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
+		return
+	} else if existing != nil {
+		respondWithJSON(w, http.StatusOK, existing)
 		return
 	}
 
-	u := models.GetShortenURL(requestBody.URL)
-
-	if requestBody.CustomShortKey != "" && !validateShortKey(requestBody.CustomShortKey) {
-		respondWithError(w, http.StatusBadRequest, "Invalid custom short key")
+	if err := u.CreateShortURL(App.Store, App.keyGenerator()); errors.Is(err, models.ErrShortKeyExists) {
+		respondWithRequestError(w, errShortKeyTaken)
 		return
-	} else if requestBody.CustomShortKey != "" && !models.CheckShortKeyAvailability(App.DB, requestBody.CustomShortKey) {
-		respondWithError(w, http.StatusNotAcceptable, "Short key not available to use")
+	} else if errors.Is(err, models.ErrNoShortKeyLeft) {
+		respondWithRequestError(w, errNoShortKeyLeft)
 		return
-	}
-
-	u.ShortKey = requestBody.CustomShortKey
-
-	if err := u.CreateShortURL(App.DB); err != nil {
+	} else if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong. Please try again.")
 		return
 	}
 
 	App.wg.Add(1)
-	go setRedisKey(App.Redis, context.Background(), App.wg, u.ShortKey, u, 24*time.Hour)
+	go setCacheKey(App.Cache, context.Background(), App.wg, u.ShortKey, u, linkCacheTTL(u))
 
 	respondWithJSON(w, http.StatusCreated, &u)
 }
 
+// prepareShortenURL validates a shorten request and builds the link it
+// describes, ready to be inserted.
+func prepareShortenURL(req *ShortenURLRequest, apiKey *models.APIKey) (*models.ShortenURL, *requestError) {
+	if req.URL == "" {
+		return nil, &requestError{http.StatusBadRequest, "url_missing", "URL not given"}
+	}
+
+	normalizedURL, reqErr := checkDestination(req.URL)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	u := models.GetShortenURL(req.URL)
+	u.NormalizedURL = normalizedURL
+
+	if req.CustomShortKey != "" {
+		if reqErr := checkCustomShortKey(req.CustomShortKey); reqErr != nil {
+			return nil, reqErr
+		}
+	}
+
+	u.ShortKey = req.CustomShortKey
+
+	u.RedirectType = App.redirectType
+	if req.RedirectType != 0 {
+		if !validRedirectType(req.RedirectType) {
+			return nil, &requestError{http.StatusBadRequest, "redirect_type_invalid", "Invalid redirect_type, expected 301, 302, 307 or 308"}
+		}
+		u.RedirectType = req.RedirectType
+	}
+
+	if apiKey != nil {
+		u.APIKeyID = apiKey.ID
+	}
+
+	expireTime, err := resolveExpireTime(&req.ExpiryRequest, apiKey)
+	if errors.Is(err, errNoExpiryForbidden) {
+		return nil, &requestError{http.StatusForbidden, "no_expiry_forbidden", err.Error()}
+	} else if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "expiry_invalid", err.Error()}
+	}
+	u.ExpireTime = expireTime
+
+	if u.Tags, err = models.NormalizeTags(req.Tags); err != nil {
+		return nil, &requestError{http.StatusBadRequest, "tags_invalid", err.Error()}
+	}
+
+	return u, nil
+}
+
+// checkDestination validates and normalizes the URL a link points to, and
+// makes sure it passes the URL safety checks. Normalizing can make a URL
+// longer, as with punycode hosts and escaped paths, so both forms must fit
+// in MAX_URL_LENGTH.
+func checkDestination(rawURL string) (string, *requestError) {
+	normalizedURL, err := App.normalizer.Normalize(rawURL)
+	if !validateURL(rawURL) || err != nil {
+		return "", &requestError{http.StatusBadRequest, "url_invalid", "Invalid URL given"}
+	}
+
+	if len(rawURL) > constants.MAX_URL_LENGTH || len(normalizedURL) > constants.MAX_URL_LENGTH {
+		return "", &requestError{http.StatusBadRequest, "url_too_long", fmt.Sprintf("URL must be at most %d characters long", constants.MAX_URL_LENGTH)}
+	}
+
+	parsed, err := url.Parse(normalizedURL)
+	if err != nil {
+		return "", &requestError{http.StatusBadRequest, "url_invalid", "Invalid URL given"}
+	}
+
+	switch err := App.urlSafety.Check(parsed); {
+	case errors.Is(err, urlsafety.ErrSchemeNotAllowed):
+		return "", &requestError{http.StatusBadRequest, "scheme_not_allowed", "URL scheme is not allowed"}
+	case errors.Is(err, urlsafety.ErrMissingHost), errors.Is(err, urlnorm.ErrInvalidHost):
+		return "", &requestError{http.StatusBadRequest, "url_invalid", "Invalid URL given"}
+	case errors.Is(err, urlsafety.ErrDomainBlocked):
+		return "", &requestError{http.StatusForbidden, "domain_blocked", "URL domain is blocked"}
+	case errors.Is(err, urlsafety.ErrDomainNotAllowed):
+		return "", &requestError{http.StatusForbidden, "domain_not_allowed", "URL domain is not allowed"}
+	case errors.Is(err, urlsafety.ErrSelfReference):
+		return "", &requestError{http.StatusBadRequest, "self_reference", "URL must not point to this URL shortener"}
+	case errors.Is(err, urlsafety.ErrChainedShortener):
+		return "", &requestError{http.StatusBadRequest, "chained_shortener", "URL must not point to another URL shortener"}
+	}
+
+	return normalizedURL, nil
+}
+
+// checkCustomShortKey validates a requested short key and makes sure it is
+// neither reserved nor taken.
+func checkCustomShortKey(key string) *requestError {
+	if !validateCustomShortKey(key) {
+		return &requestError{http.StatusBadRequest, "custom_key_invalid", "Invalid custom short key"}
+	}
+
+	if err := App.reserved.Check(key); errors.Is(err, reserved.ErrReservedKey) {
+		return &requestError{http.StatusNotAcceptable, "custom_key_reserved", "Short key is reserved"}
+	} else if errors.Is(err, reserved.ErrBlockedWord) {
+		return &requestError{http.StatusNotAcceptable, "custom_key_blocked", "Short key contains a blocked word"}
+	}
+
+	if !models.CheckShortKeyAvailability(App.Store, key) {
+		return errShortKeyTaken
+	}
+	return nil
+}
+
+func dedupeRequested(req *ShortenURLRequest) bool {
+	dedupe := App.dedupeLinks
+	if req.Dedupe != nil {
+		dedupe = *req.Dedupe
+	}
+	return dedupe && req.CustomShortKey == ""
+}
+
+// findDuplicateLink returns the newest active link the owner already has for
+// the same URL when deduplication applies to the request and that link has
+// the requested options, or nil.
+func findDuplicateLink(req *ShortenURLRequest, u *models.ShortenURL) (*models.ShortenURL, error) {
+	if !dedupeRequested(req) {
+		return nil, nil
+	}
+
+	existing := models.ShortenURL{NormalizedURL: u.NormalizedURL, APIKeyID: u.APIKeyID}
+	if err := existing.FetchActiveShortURLData(App.Store); errors.Is(err, models.ErrShortURLNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !matchesLink(req, u, &existing) {
+		return nil, nil
+	}
+	return &existing, nil
+}
+
+// matchesLink reports whether existing has the redirect type, tags and expiry
+// requested for u. A ttl or the default expiry only fixes the day a link
+// expires on, so any expiry on that day matches.
+func matchesLink(req *ShortenURLRequest, u, existing *models.ShortenURL) bool {
+	if u.RedirectType != existing.RedirectType || len(u.Tags) != len(existing.Tags) {
+		return false
+	}
+	for _, tag := range u.Tags {
+		if !slices.Contains(existing.Tags, tag) {
+			return false
+		}
+	}
+
+	switch {
+	case u.ExpireTime == nil || existing.ExpireTime == nil:
+		return u.ExpireTime == nil && existing.ExpireTime == nil
+	case req.ExpireTime != nil:
+		return u.ExpireTime.Truncate(time.Second).Equal(existing.ExpireTime.Truncate(time.Second))
+	default:
+		return u.ExpireTime.UTC().Truncate(24 * time.Hour).Equal(existing.ExpireTime.UTC().Truncate(24 * time.Hour))
+	}
+}
+
 func HandleRedirectToOriginalURL(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
@@ -65,18 +264,80 @@ func HandleRedirectToOriginalURL(w http.ResponseWriter, r *http.Request) {
 
 	var u models.ShortenURL
 
-	if err := getRedisKey(App.Redis, context.Background(), vars["key"], &u); err != nil {
+	if err := getCacheKey(App.Cache, context.Background(), vars["key"], &u); err != nil {
 		u.ShortKey = vars["key"]
-		u.FetchShortURLData(App.DB)
+		u.FetchShortURLData(App.Store)
 	}
 
-	if u.OriginalURL == "" || u.ExpireTime.Before(time.Now()) {
-		App.wg.Add(2)
-		go u.DeleteShortURLData(App.DB, App.wg)
-		go deleteRedisKey(App.Redis, context.Background(), App.wg, vars["key"])
+	if u.OriginalURL == "" || u.Disabled {
 		respondWithError(w, http.StatusNotFound, "Short Key not found")
 		return
 	}
 
-	http.Redirect(w, r, u.OriginalURL, http.StatusMovedPermanently)
+	if u.Expired() {
+		respondWithError(w, http.StatusGone, "Short Key expired")
+		return
+	}
+
+	recordClick(r, vars["key"])
+
+	status := linkRedirectType(&u)
+	w.Header().Set("Cache-Control", redirectCacheControl(&u, status))
+	http.Redirect(w, r, u.OriginalURL, status)
+}
+
+func resolveExpireTime(req *ExpiryRequest, apiKey *models.APIKey) (*time.Time, error) {
+	if req.NoExpiry {
+		if req.ExpireTime != nil || req.TTL != "" {
+			return nil, errors.New("no_expiry cannot be combined with expire_time or ttl")
+		}
+		if apiKey == nil || !apiKey.AllowNoExpiry {
+			return nil, errNoExpiryForbidden
+		}
+		return nil, nil
+	}
+
+	if req.ExpireTime != nil && req.TTL != "" {
+		return nil, errors.New("Only one of expire_time and ttl can be given")
+	}
+
+	now := time.Now()
+	maxTTL := App.maxLinkTTL
+	if apiKey != nil && apiKey.MaxTTL > 0 {
+		maxTTL = apiKey.MaxTTL
+	}
+
+	var expireTime time.Time
+	switch {
+	case req.ExpireTime != nil:
+		expireTime = *req.ExpireTime
+	case req.TTL != "":
+		ttl, err := config.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, errors.New("Invalid ttl")
+		}
+		expireTime = now.Add(ttl)
+	default:
+		expireTime = models.DefaultExpireTime()
+		if maxTTL > 0 && expireTime.After(now.Add(maxTTL)) {
+			expireTime = now.Add(maxTTL)
+		}
+		return &expireTime, nil
+	}
+
+	if !expireTime.After(now) {
+		return nil, errors.New("Expiry must be in the future")
+	}
+	if maxTTL > 0 && expireTime.After(now.Add(maxTTL)) {
+		return nil, fmt.Errorf("Expiry exceeds the maximum allowed ttl of %s", maxTTL)
+	}
+
+	return &expireTime, nil
+}
+
+func linkCacheTTL(u *models.ShortenURL) time.Duration {
+	if u.ExpireTime == nil {
+		return App.cacheTTL
+	}
+	return time.Until(*u.ExpireTime)
 }
@@ -5,14 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Conero007/url-shortener/cache"
 	"github.com/Conero007/url-shortener/constants"
-	"github.com/redis/go-redis/v9"
 )
 
 func respondWithError(w http.ResponseWriter, code int, message string) {
@@ -31,41 +34,100 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	}
 }
 
-func setRedisKey(r *redis.Client, ctx context.Context, wg *sync.WaitGroup, key string, value interface{}, ttl time.Duration) {
+func setCacheKey(c cache.Cache, ctx context.Context, wg *sync.WaitGroup, key string, value interface{}, ttl time.Duration) {
 	var err error
 	var val []byte
 	defer wg.Done()
 
 	if val, err = json.Marshal(value); err == nil {
-		_, err = r.Set(ctx, key, val, ttl).Result()
+		err = c.Set(ctx, key, val, ttl)
 	}
 
 	if err != nil {
-		log.Println("[Error] Could not set key in redis ", err)
+		log.Println("[Error] Could not set key in cache ", err)
 	}
 }
 
-func getRedisKey(r *redis.Client, ctx context.Context, key string, dest interface{}) error {
+func getCacheKey(c cache.Cache, ctx context.Context, key string, dest interface{}) error {
 	var err error
-	var val string
+	var val []byte
 
-	if val, err = r.Get(ctx, key).Result(); err == nil {
-		return json.Unmarshal([]byte(val), dest)
+	if val, err = c.Get(ctx, key); err == nil {
+		return json.Unmarshal(val, dest)
 	}
 
-	if err != redis.Nil {
-		log.Println("[Error] Could not get key in redis ", err)
+	if err != cache.ErrCacheMiss {
+		log.Println("[Error] Could not get key in cache ", err)
 	}
 
 	return err
 }
 
-func deleteRedisKey(r *redis.Client, ctx context.Context, wg *sync.WaitGroup, keys ...string) {
+func deleteCacheKey(c cache.Cache, ctx context.Context, wg *sync.WaitGroup, keys ...string) {
 	defer wg.Done()
 
-	if _, err := r.Del(ctx, keys...).Result(); err != nil {
-		log.Println("[Error] Could not delete key in redis ", err)
+	if err := c.Delete(ctx, keys...); err != nil {
+		log.Println("[Error] Could not delete key in cache ", err)
+	}
+}
+
+// getClientIP returns the IP of the client. Behind a trusted proxy it is the
+// right-most X-Forwarded-For hop that is not a trusted proxy itself, since
+// the hops left of it are whatever the client sent.
+func getClientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !App.fromTrustedProxy(r) {
+		return ip
+	}
+
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			if !App.trustedProxy(hop) {
+				return hop
+			}
+			ip = hop
+		}
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
 	}
+	return ip
+}
+
+// fromTrustedProxy reports whether the request was sent by a trusted proxy,
+// so its proxy headers can be believed.
+func (a *AppConfig) fromTrustedProxy(r *http.Request) bool {
+	return a.trustProxy && a.trustedProxy(remoteIP(r))
+}
+
+func (a *AppConfig) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range a.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
 func validateURL(originalURL string) bool {
@@ -75,10 +137,14 @@ func validateURL(originalURL string) bool {
 	return true
 }
 
+var shortKeyPattern = regexp.MustCompile(fmt.Sprintf(`^[A-Za-z0-9]{1,%d}$`, constants.MAX_SHORT_KEY_LENGTH))
+
+// validateShortKey accepts any key a link may have, whatever the key length
+// settings were when it was created.
 func validateShortKey(shortKey string) bool {
-	regexPattern := fmt.Sprintf(`^[A-Z a-z 0-9]{%d}$`, constants.SHORT_KEY_LENGTH)
-	if ok, _ := regexp.MatchString(regexPattern, shortKey); !ok {
-		return false
-	}
-	return true
+	return shortKeyPattern.MatchString(shortKey)
+}
+
+func validateCustomShortKey(shortKey string) bool {
+	return len(shortKey) >= App.customKeyMin && len(shortKey) <= App.customKeyMax && shortKeyPattern.MatchString(shortKey)
 }
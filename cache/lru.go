@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	maxTTL  time.Duration
+	items   map[string]*list.Element
+	entries *list.List
+}
+
+func NewLRUCache(size int, maxTTL time.Duration) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		maxTTL:  maxTTL,
+		items:   make(map[string]*list.Element),
+		entries: list.New(),
+	}
+}
+
+func (c *LRUCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	entry := el.Value.(*lruEntry)
+	if !entry.expireAt.IsZero() && entry.expireAt.Before(time.Now()) {
+		c.removeElement(el)
+		return nil, ErrCacheMiss
+	}
+
+	c.entries.MoveToFront(el)
+	return entry.value, nil
+}
+
+func (c *LRUCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxTTL > 0 && (ttl <= 0 || ttl > c.maxTTL) {
+		ttl = c.maxTTL
+	}
+
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expireAt = expireAt
+		c.entries.MoveToFront(el)
+		return nil
+	}
+
+	c.items[key] = c.entries.PushFront(&lruEntry{key: key, value: value, expireAt: expireAt})
+
+	for c.size > 0 && c.entries.Len() > c.size {
+		c.removeElement(c.entries.Back())
+	}
+
+	return nil
+}
+
+func (c *LRUCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+		}
+	}
+	return nil
+}
+
+func (c *LRUCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.entries.Init()
+}
+
+func (c *LRUCache) Close() error {
+	return nil
+}
+
+func (c *LRUCache) removeElement(el *list.Element) {
+	c.entries.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
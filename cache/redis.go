@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisCache struct {
+	Client *redis.Client
+}
+
+func NewRedisCache(addr, password string) (*RedisCache, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       0,
+	})
+
+	if _, err := client.Ping(context.Background()).Result(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisCache{Client: client}, nil
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := c.Client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+	return val, err
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.Client.Set(ctx, key, value, ttl).Err()
+}
+
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	return c.Client.Del(ctx, keys...).Err()
+}
+
+func (c *RedisCache) Close() error {
+	return c.Client.Close()
+}
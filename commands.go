@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Conero007/url-shortener/app"
+	"github.com/Conero007/url-shortener/config"
+	"github.com/Conero007/url-shortener/constants"
+	"github.com/Conero007/url-shortener/database"
+	"github.com/Conero007/url-shortener/models"
+	"github.com/Conero007/url-shortener/storage"
+)
+
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrateCommand(args[1:])
+	case "apikey":
+		return runAPIKeyCommand(args[1:])
+	case "links":
+		return runLinksCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [N] | status")
+	}
+
+	store, err := openSQLStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	driver := store.Dialect.Name
+
+	switch args[0] {
+	case "up":
+		ran, err := database.MigrateUp(store.DB, driver)
+		for _, m := range ran {
+			fmt.Printf("Applied %d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to roll back: %q", args[1])
+			}
+		}
+		rolledBack, err := database.MigrateDown(store.DB, driver, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("Rolled back %d %s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		status, err := database.GetMigrationStatus(store.DB, driver)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func runAPIKeyCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: apikey create <name> [--max-ttl DURATION] [--allow-no-expiry] | list | revoke <id>")
+	}
+
+	store, err := openSQLStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.Migrate(); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		usage := fmt.Errorf("usage: apikey create <name> [--max-ttl DURATION] [--allow-no-expiry]")
+		if len(args) < 2 || args[1] == "" {
+			return usage
+		}
+		flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		maxTTL := flags.String("max-ttl", "", "longest expiry links created with this key may have, e.g. 30d")
+		allowNoExpiry := flags.Bool("allow-no-expiry", false, "allow links that never expire")
+		if err := flags.Parse(args[2:]); err != nil {
+			return usage
+		}
+		apiKey, key, err := models.NewAPIKey(args[1])
+		if err != nil {
+			return err
+		}
+		if *maxTTL != "" {
+			if apiKey.MaxTTL, err = config.ParseDuration(*maxTTL); err != nil || apiKey.MaxTTL <= 0 {
+				return fmt.Errorf("invalid max ttl %q", *maxTTL)
+			}
+		}
+		apiKey.AllowNoExpiry = *allowNoExpiry
+		if err := store.InsertAPIKey(apiKey); err != nil {
+			return err
+		}
+		fmt.Printf("Created API key %d (%s)\n", apiKey.ID, apiKey.Name)
+		fmt.Println("Key:", key)
+		fmt.Println("Store it safely, it cannot be shown again.")
+		return nil
+	case "list":
+		keys, err := store.ListAPIKeys()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tMAX TTL\tNO EXPIRY\tCREATED AT\tSTATUS")
+		for _, k := range keys {
+			status := "active"
+			if !k.Active() {
+				status = "revoked"
+			}
+			maxTTL := "default"
+			if k.MaxTTL > 0 {
+				maxTTL = k.MaxTTL.String()
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%t\t%s\t%s\n", k.ID, k.Name, k.Prefix, maxTTL, k.AllowNoExpiry, k.CreatedAt.Format("2006-01-02 15:04:05"), status)
+		}
+		return w.Flush()
+	case "revoke":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: apikey revoke <id>")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid API key id %q", args[1])
+		}
+		if err := store.RevokeAPIKey(id); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked API key %d\n", id)
+		return nil
+	default:
+		return fmt.Errorf("unknown apikey command %q", args[0])
+	}
+}
+
+func runLinksCommand(args []string) error {
+	usage := fmt.Errorf("usage: links import <file.csv> --key-id ID | export --key-id ID [--format csv|ndjson]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	var file string
+	flagArgs := args[1:]
+	if args[0] == "import" {
+		if len(args) < 2 {
+			return usage
+		}
+		file, flagArgs = args[1], args[2:]
+	}
+
+	flags := flag.NewFlagSet("links "+args[0], flag.ContinueOnError)
+	keyID := flags.Int64("key-id", 0, "id of the API key owning the links")
+	format := flags.String("format", "csv", "export format, csv or ndjson")
+	if err := flags.Parse(flagArgs); err != nil || *keyID == 0 {
+		return usage
+	}
+
+	a, apiKey, err := openLinksApp(*keyID)
+	if err != nil {
+		return err
+	}
+	defer a.Shutdown()
+
+	switch args[0] {
+	case "import":
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		result, err := a.ImportLinksCSV(f, apiKey)
+		if err != nil {
+			return err
+		}
+		for _, e := range result.Errors {
+			fmt.Printf("Line %d: %s\n", e.Line, e.Error)
+		}
+		fmt.Printf("Imported %d links, %d already existed, %d failed\n", result.Created, result.Existing, result.Failed)
+		return nil
+	case "export":
+		if *format != "csv" && *format != "ndjson" {
+			return usage
+		}
+		return a.ExportLinks(os.Stdout, apiKey, *format)
+	default:
+		return fmt.Errorf("unknown links command %q", args[0])
+	}
+}
+
+func openLinksApp(keyID int64) (*app.AppConfig, *models.APIKey, error) {
+	a := app.NewApp(false)
+
+	if err := a.InitializeDB(
+		config.GetString("DB_DRIVER", "mysql"),
+		os.Getenv("DB_ADDR"),
+		os.Getenv("DB_USERNAME"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_NAME"),
+	); err != nil {
+		return nil, nil, err
+	}
+
+	if err := a.InitializeCache(
+		os.Getenv("CACHE_DRIVER"),
+		os.Getenv("REDIS_ADDR"),
+		os.Getenv("REDIS_PASSWORD"),
+		config.GetInt("CACHE_SIZE", constants.DEFAULT_CACHE_SIZE),
+		config.GetDuration("CACHE_TTL", constants.DEFAULT_CACHE_TTL),
+	); err != nil {
+		a.Store.Close()
+		return nil, nil, err
+	}
+
+	if err := initializeLinks(a); err != nil {
+		a.Shutdown()
+		return nil, nil, err
+	}
+
+	keys, err := a.Store.ListAPIKeys()
+	if err != nil {
+		a.Shutdown()
+		return nil, nil, err
+	}
+	for i := range keys {
+		if keys[i].ID == keyID && keys[i].Active() {
+			return a, &keys[i], nil
+		}
+	}
+
+	a.Shutdown()
+	return nil, nil, fmt.Errorf("no active API key with id %d", keyID)
+}
+
+func openSQLStore() (*storage.SQLStore, error) {
+	return storage.NewSQLStore(
+		config.GetString("DB_DRIVER", "mysql"),
+		os.Getenv("DB_ADDR"),
+		os.Getenv("DB_USERNAME"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_NAME"),
+	)
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func GetString(key, fallback string) string {
+	if val, ok := os.LookupEnv(key); ok && val != "" {
+		return val
+	}
+	return fallback
+}
+
+func GetInt(key string, fallback int) int {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("[Error] Invalid integer %q for %s, using %d", val, key, fallback)
+		return fallback
+	}
+	return i
+}
+
+func GetBool(key string, fallback bool) bool {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Printf("[Error] Invalid boolean %q for %s, using %t", val, key, fallback)
+		return fallback
+	}
+	return b
+}
+
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback
+	}
+
+	d, err := ParseDuration(val)
+	if err != nil {
+		log.Printf("[Error] Invalid duration %q for %s, using %s", val, key, fallback)
+		return fallback
+	}
+	return d
+}
+
+// ParseDuration accepts anything time.ParseDuration does, plus a whole
+// number of days such as "30d".
+func ParseDuration(val string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(val, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", val)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(val)
+}
+
+func GetList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
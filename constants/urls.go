@@ -1,9 +1,73 @@
 package constants
 
+import "time"
+
 const (
-	SHORT_KEY_LENGTH               = 6
-	RANDOM_KEY_LENGTH              = 6
+	DEFAULT_SHORT_KEY_LENGTH       = 6
+	MAX_SHORT_KEY_LENGTH           = 20
 	GENERATE_SHORT_KEY_MAX_ATTEMPT = 5
+	GENERATE_SHORT_KEY_MAX_SKIPS   = 100
+	DEFAULT_KEY_GENERATOR          = "random"
+	KEY_SEQUENCE_BLOCK_SIZE        = 100
+
+	DEFAULT_KEY_ALPHABET              = "base62"
+	DEFAULT_KEY_LENGTH_GROWTH_PERCENT = 50
+	KEY_LENGTH_CHECK_INTERVAL         = 10 * time.Minute
+
+	DEFAULT_CUSTOM_KEY_MIN_LENGTH = 4
+	DEFAULT_CUSTOM_KEY_MAX_LENGTH = MAX_SHORT_KEY_LENGTH
+
+	DEFAULT_KEY_POOL_SIZE              = 0
+	DEFAULT_KEY_POOL_LOW_WATERMARK     = 1000
+	DEFAULT_KEY_POOL_REFILL_INTERVAL   = 10 * time.Second
+	DEFAULT_KEY_POOL_REFILL_BATCH_SIZE = 500
+	KEY_POOL_TAKE_SIZE                 = 20
+
+	BASE_62_CHARACTERS     = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	UNAMBIGUOUS_CHARACTERS = "23456789ABCDEFGHIJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+	DEFAULT_CACHE_SIZE = 10000
+	DEFAULT_CACHE_TTL  = 24 * time.Hour
+
+	DEFAULT_ANALYTICS_BUFFER_SIZE    = 10000
+	DEFAULT_ANALYTICS_BATCH_SIZE     = 100
+	DEFAULT_ANALYTICS_FLUSH_INTERVAL = 5 * time.Second
+	DEFAULT_COUNTRY_HEADER           = "X-Country-Code"
+
+	DEFAULT_CLICK_COUNTS_FLUSH_INTERVAL = 30 * time.Second
+
+	DEFAULT_RATE_LIMIT_SHORTEN  = "60/1m"
+	DEFAULT_RATE_LIMIT_REDIRECT = "600/1m"
+
+	DEFAULT_MAX_LINK_TTL = 365 * 24 * time.Hour
+
+	DEFAULT_EXPIRY_SWEEP_INTERVAL   = time.Minute
+	DEFAULT_EXPIRY_SWEEP_BATCH_SIZE = 500
+	DEFAULT_ARCHIVE_QUARANTINE      = 30 * 24 * time.Hour
+
+	DEFAULT_REDIRECT_TYPE              = 302
+	DEFAULT_PERMANENT_REDIRECT_MAX_AGE = 24 * time.Hour
+
+	DEFAULT_BATCH_MAX_ITEMS = 100
+	MAX_BATCH_ITEM_BYTES    = 4 * 1024
+
+	DEFAULT_TRACKING_PARAMS = "utm_*,fbclid,gclid,msclkid,mc_cid,mc_eid"
+
+	MAX_URL_LENGTH = 255
+
+	DEFAULT_URL_SCHEMES       = "http,https"
+	DEFAULT_SHORTENER_DOMAINS = "bit.ly,bl.ink,buff.ly,cutt.ly,goo.gl,is.gd,lnkd.in,ow.ly,rb.gy,rebrand.ly,s.id,shorturl.at,t.co,t.ly,tiny.cc,tinyurl.com,v.gd"
+
+	DEFAULT_IDEMPOTENCY_KEY_TTL = 24 * time.Hour
+	MAX_IDEMPOTENCY_KEY_LENGTH  = 255
+
+	MAX_TAGS_PER_LINK       = 10
+	MAX_TAG_LENGTH          = 32
+	DEFAULT_LINKS_PAGE_SIZE = 20
+	MAX_LINKS_PAGE_SIZE     = 100
 
-	BASE_62_CHARACTERS = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	DEFAULT_STATS_RANGE    = 30 * 24 * time.Hour
+	MAX_STATS_RANGE        = 366 * 24 * time.Hour
+	MAX_STATS_DETAIL_RANGE = 7 * 24 * time.Hour
+	STATS_TOP_LIMIT        = 10
 )
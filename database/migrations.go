@@ -2,92 +2,232 @@ package database
 
 import (
 	"database/sql"
+	"embed"
 	"encoding/json"
-	"io"
+	"fmt"
+	"io/fs"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+//go:embed migrations/*.json
+var embeddedMigrations embed.FS
+
+var migrationsFS, _ = fs.Sub(embeddedMigrations, "migrations")
+
+func SetMigrationsDir(dir string) {
+	if dir != "" {
+		migrationsFS = os.DirFS(dir)
+	}
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 type Migration struct {
+	Version  int64  `json:"version"`
+	Name     string `json:"name"`
 	Query    string `json:"query"`
 	Rollback string `json:"rollback"`
 }
 
-func (m Migration) RunQuery(db *sql.DB) error {
-	_, err := db.Exec(m.Query)
-	return err
+func (m Migration) RunQuery(db execer) error {
+	return execStatements(db, m.Query)
 }
 
-func (m Migration) RollbackQuery(db *sql.DB) error {
-	_, err := db.Exec(m.Rollback)
-	return err
+func (m Migration) RollbackQuery(db execer) error {
+	return execStatements(db, m.Rollback)
 }
 
-type Migrations map[string]Migration
+type Migrations []Migration
 
-func getMigrations() (Migrations, error) {
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+func getMigrations(driver string) (Migrations, error) {
 	var m Migrations
 
-	file, err := os.Open("database/migrations.json")
+	fileContent, err := fs.ReadFile(migrationsFS, driver+".json")
 	if err != nil {
 		return m, err
 	}
-	defer file.Close()
 
-	fileContent, err := io.ReadAll(file)
-	if err != nil {
+	if err := json.Unmarshal(fileContent, &m); err != nil {
 		return m, err
 	}
 
-	if err := json.Unmarshal(fileContent, &m); err != nil {
-		return m, err
+	sort.Slice(m, func(i, j int) bool { return m[i].Version < m[j].Version })
+
+	for i := range m {
+		if m[i].Version <= 0 {
+			return nil, fmt.Errorf("migration %q has an invalid version %d", m[i].Name, m[i].Version)
+		}
+		if i > 0 && m[i].Version == m[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m[i].Version)
+		}
 	}
 
 	return m, nil
 }
 
-func RunMigrations(db *sql.DB, dbName string) error {
-	if err := createAndUseDatabase(db, dbName); err != nil {
-		return err
-	}
+func RunMigrations(db *sql.DB, driver string) error {
+	_, err := MigrateUp(db, driver)
+	return err
+}
 
-	migrations, err := getMigrations()
+func MigrateUp(db *sql.DB, driver string) (Migrations, error) {
+	migrations, applied, err := loadState(db, driver)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var ran Migrations
 	for _, migration := range migrations {
-		if err := migration.RunQuery(db); err != nil {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := inTransaction(db, func(tx *sql.Tx) error {
+			if err := migration.RunQuery(tx); err != nil {
+				return err
+			}
+			query := Rebind(driver, "INSERT INTO schema_migrations(version, name, applied_at) VALUES(?, ?, ?)")
+			_, err := tx.Exec(query, migration.Version, migration.Name, time.Now().UTC())
 			return err
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
 		}
+
+		ran = append(ran, migration)
 	}
 
-	return err
+	return ran, nil
 }
 
-func RollbackMigrations(db *sql.DB, migrationNames ...string) error {
-	migrations, err := getMigrations()
+func MigrateDown(db *sql.DB, driver string, steps int) (Migrations, error) {
+	migrations, applied, err := loadState(db, driver)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, migrationName := range migrationNames {
-		if _, ok := migrations[migrationName]; ok {
-			if err = migrations[migrationName].RollbackQuery(db); err != nil {
+	var rolledBack Migrations
+	for i := len(migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := inTransaction(db, func(tx *sql.Tx) error {
+			if err := migration.RollbackQuery(tx); err != nil {
 				return err
 			}
+			_, err := tx.Exec(Rebind(driver, "DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+			return err
+		})
+		if err != nil {
+			return rolledBack, fmt.Errorf("rollback %d (%s): %w", migration.Version, migration.Name, err)
 		}
 
+		rolledBack = append(rolledBack, migration)
 	}
 
-	return nil
+	return rolledBack, nil
+}
+
+func GetMigrationStatus(db *sql.DB, driver string) ([]MigrationStatus, error) {
+	migrations, applied, err := loadState(db, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		s := MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
 }
 
-func createAndUseDatabase(db *sql.DB, dbName string) error {
-	if _, err := db.Exec("CREATE DATABASE IF NOT EXISTS " + dbName); err != nil {
+func loadState(db *sql.DB, driver string) (Migrations, map[int64]time.Time, error) {
+	migrations, err := getMigrations(driver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)"); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return migrations, applied, rows.Err()
+}
+
+func inTransaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
 		return err
 	}
-	if _, err := db.Exec("USE " + dbName); err != nil {
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
 		return err
 	}
+
+	return tx.Commit()
+}
+
+func execStatements(db execer, statements string) error {
+	for _, statement := range strings.Split(statements, ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func Rebind(driver, query string) string {
+	if driver != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
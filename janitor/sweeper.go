@@ -0,0 +1,139 @@
+package janitor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/cache"
+	"github.com/Conero007/url-shortener/models"
+)
+
+type Metrics struct {
+	Sweeps       int64      `json:"sweeps"`
+	RowsArchived int64      `json:"rows_archived"`
+	RowsPurged   int64      `json:"rows_purged"`
+	Errors       int64      `json:"errors"`
+	LastSweepAt  *time.Time `json:"last_sweep_at"`
+	LastArchived int64      `json:"last_archived"`
+	LastPurged   int64      `json:"last_purged"`
+}
+
+// Sweeper periodically archives expired short URLs and, once they have been
+// archived for longer than the quarantine period, deletes them so their keys
+// can be reissued. Rows are processed in batches of at most batchSize.
+type Sweeper struct {
+	store      models.URLStore
+	cache      cache.Cache
+	interval   time.Duration
+	quarantine time.Duration
+	batchSize  int
+
+	mu      sync.Mutex
+	metrics Metrics
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewSweeper(store models.URLStore, c cache.Cache, interval, quarantine time.Duration, batchSize int) *Sweeper {
+	s := &Sweeper{
+		store:      store,
+		cache:      c,
+		interval:   interval,
+		quarantine: quarantine,
+		batchSize:  batchSize,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+func (s *Sweeper) Sweep() (archived, purged int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	archived, err = s.inBatches(func() ([]string, error) {
+		return s.store.ArchiveExpiredShortURLs(now, s.batchSize)
+	})
+	if err == nil {
+		purged, err = s.inBatches(func() ([]string, error) {
+			return s.store.PurgeArchivedShortURLs(now.Add(-s.quarantine), s.batchSize)
+		})
+	}
+
+	sweptAt := now.UTC()
+	s.metrics.Sweeps++
+	s.metrics.RowsArchived += int64(archived)
+	s.metrics.RowsPurged += int64(purged)
+	s.metrics.LastSweepAt = &sweptAt
+	s.metrics.LastArchived = int64(archived)
+	s.metrics.LastPurged = int64(purged)
+	if err != nil {
+		s.metrics.Errors++
+	}
+
+	return archived, purged, err
+}
+
+func (s *Sweeper) inBatches(fn func() ([]string, error)) (int, error) {
+	var total int
+	for {
+		keys, err := fn()
+		if err != nil || len(keys) == 0 {
+			return total, err
+		}
+
+		total += len(keys)
+		if err := s.cache.Delete(context.Background(), keys...); err != nil {
+			log.Println("[Error] Could not evict swept keys from cache ", err)
+		}
+
+		if len(keys) < s.batchSize || s.stopping() {
+			return total, nil
+		}
+	}
+}
+
+func (s *Sweeper) Metrics() Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.metrics
+}
+
+func (s *Sweeper) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Sweeper) stopping() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Sweeper) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, _, err := s.Sweep(); err != nil {
+				log.Println("[Error] Could not sweep expired short urls ", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package keygen
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+)
+
+const feistelRounds = 4
+
+// feistel is a keyed permutation of [0, keyspace). It runs a balanced Feistel
+// network over the smallest even number of bits covering the keyspace, and
+// walks the cycle until the result falls back inside it.
+type feistel struct {
+	keyspace uint64
+	halfBits uint
+	mask     uint64
+	keys     [feistelRounds]uint64
+}
+
+func newFeistel(keyspace uint64, secret string) *feistel {
+	width := uint(bits.Len64(keyspace - 1))
+	if width%2 == 1 {
+		width++
+	}
+
+	f := &feistel{keyspace: keyspace, halfBits: width / 2, mask: 1<<(width/2) - 1}
+
+	sum := sha256.Sum256([]byte(secret))
+	for i := range f.keys {
+		f.keys[i] = binary.BigEndian.Uint64(sum[i*8:])
+	}
+	return f
+}
+
+func (f *feistel) permute(n uint64) uint64 {
+	for {
+		n = f.encrypt(n)
+		if n < f.keyspace {
+			return n
+		}
+	}
+}
+
+func (f *feistel) encrypt(n uint64) uint64 {
+	left, right := n>>f.halfBits, n&f.mask
+	for _, key := range f.keys {
+		left, right = right, left^(mix(right^key)&f.mask)
+	}
+	return left<<f.halfBits | right
+}
+
+// mix is the splitmix64 finalizer.
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,293 @@
+package keygen
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/constants"
+	"github.com/Conero007/url-shortener/models"
+)
+
+const (
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+	StrategyHash    = "hash"
+)
+
+// ErrKeyspaceExhausted wraps models.ErrNoShortKeyLeft, so callers answer it
+// like any other failure to allocate a key.
+var ErrKeyspaceExhausted = fmt.Errorf("short key space exhausted: %w", models.ErrNoShortKeyLeft)
+
+type strategy interface {
+	// next returns a number in [0, keyspace) to be encoded as a key. attempt
+	// counts the collisions already seen for this URL.
+	next(url string, attempt int) (uint64, error)
+}
+
+type Stats struct {
+	Strategy      string  `json:"strategy"`
+	Generated     int64   `json:"generated"`
+	Collisions    int64   `json:"collisions"`
+	Exhausted     int64   `json:"exhausted"`
+	Rejected      int64   `json:"rejected"`
+	CollisionRate float64 `json:"collision_rate"`
+	KeyLength     int     `json:"key_length"`
+	Utilisation   float64 `json:"utilisation"`
+}
+
+// Generator produces short keys of a given length from an alphabet using one
+// of the strategies, and keeps count of the keys that turned out to be taken.
+// With a growth threshold set, the length grows by one whenever the share of
+// keys of the current length in use reaches the threshold.
+type Generator struct {
+	name     string
+	secret   string
+	alphabet string
+	growAt   float64
+	allowed  func(key string) bool
+	sequence models.KeySequenceStore
+
+	mu       sync.Mutex
+	strategy strategy
+	length   int
+	stats    Stats
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a generator for the named strategy. secret keys the permutation
+// of the counter strategy, which reserves numbers from sequence.
+// growthThreshold is the utilisation in percent at which keys get longer, 0
+// keeps the length fixed.
+func New(name, alphabet string, length, growthThreshold int, secret string, sequence models.KeySequenceStore) (*Generator, error) {
+	if length > constants.MAX_SHORT_KEY_LENGTH {
+		return nil, fmt.Errorf("keys can be at most %d characters long", constants.MAX_SHORT_KEY_LENGTH)
+	}
+	if growthThreshold < 0 || growthThreshold > 100 {
+		return nil, errors.New("growth threshold must be a percentage between 0 and 100")
+	}
+
+	g := &Generator{
+		name:     name,
+		secret:   secret,
+		alphabet: alphabet,
+		growAt:   float64(growthThreshold) / 100,
+		sequence: sequence,
+		stats:    Stats{Strategy: name},
+		wake:     make(chan struct{}, 1),
+	}
+	if err := g.setLength(length); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// setLength switches to keys of the given length. The caller holds g.mu once
+// the generator is in use.
+func (g *Generator) setLength(length int) error {
+	keyspace, err := keyspaceSize(len(g.alphabet), length)
+	if err != nil {
+		return err
+	}
+
+	var s strategy
+	switch g.name {
+	case StrategyRandom:
+		s = randomStrategy{keyspace: keyspace}
+	case StrategyHash:
+		s = hashStrategy{keyspace: keyspace}
+	case StrategyCounter:
+		if g.sequence == nil {
+			return errors.New("counter key generator requires a key sequence")
+		}
+		s = &counterStrategy{keyspace: keyspace, permutation: newFeistel(keyspace, g.secret), sequence: g.sequence}
+	default:
+		return fmt.Errorf("unsupported key generator %q", g.name)
+	}
+
+	g.strategy, g.length = s, length
+	g.stats.KeyLength = length
+	return nil
+}
+
+// SetFilter makes the generator skip keys for which allowed returns false.
+// It must be called before the generator is used.
+func (g *Generator) SetFilter(allowed func(key string) bool) {
+	g.allowed = allowed
+}
+
+// Allowed reports whether the filter lets links use key.
+func (g *Generator) Allowed(key string) bool {
+	return g.allowed == nil || g.allowed(key)
+}
+
+// GenerateKey returns a candidate key for the URL. Keys rejected by the
+// filter are skipped, up to GENERATE_SHORT_KEY_MAX_SKIPS of them.
+func (g *Generator) GenerateKey(url string, attempt int) (string, error) {
+	g.mu.Lock()
+	s, length := g.strategy, g.length
+	g.mu.Unlock()
+
+	for skipped := 0; skipped <= constants.GENERATE_SHORT_KEY_MAX_SKIPS; skipped++ {
+		// A higher attempt makes the hash strategy salt the URL, so it does
+		// not return the rejected key again.
+		n, err := s.next(url, attempt+skipped)
+		if err != nil {
+			return "", err
+		}
+
+		key := encode(n, g.alphabet, length)
+		allowed := g.Allowed(key)
+
+		g.mu.Lock()
+		if allowed {
+			g.stats.Generated++
+		} else {
+			g.stats.Rejected++
+		}
+		g.mu.Unlock()
+
+		if allowed {
+			return key, nil
+		}
+	}
+
+	return "", ErrKeyspaceExhausted
+}
+
+// RecordCollision counts a generated key that was already taken. exhausted is
+// set when no more attempts are made for the URL, which also runs the next
+// utilisation check right away.
+func (g *Generator) RecordCollision(exhausted bool) {
+	g.mu.Lock()
+	g.stats.Collisions++
+	if exhausted {
+		g.stats.Exhausted++
+	}
+	g.mu.Unlock()
+
+	if exhausted {
+		select {
+		case g.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// CheckUtilisation measures how much of the keyspace of the current length is
+// in use and grows the key length while it is at or above the threshold. Keys
+// are counted without holding g.mu, so generating keys never waits for it.
+func (g *Generator) CheckUtilisation(counter models.ShortKeyCounter) error {
+	if g.growAt == 0 {
+		return nil
+	}
+
+	for {
+		g.mu.Lock()
+		length := g.length
+		g.mu.Unlock()
+
+		used, err := counter.CountShortKeys(length)
+		if err != nil {
+			return err
+		}
+		keyspace, _ := keyspaceSize(len(g.alphabet), length)
+		utilisation := float64(used) / float64(keyspace)
+
+		g.mu.Lock()
+		g.stats.Utilisation = utilisation
+		grow := utilisation >= g.growAt && length < constants.MAX_SHORT_KEY_LENGTH
+		// When the next length does not fit in 63 bits, stay at this one.
+		grow = grow && g.setLength(length+1) == nil
+		g.mu.Unlock()
+
+		if !grow {
+			return nil
+		}
+	}
+}
+
+// Start checks the utilisation now and then every interval in the background,
+// until Close. It does nothing when the key length is fixed.
+func (g *Generator) Start(counter models.ShortKeyCounter, interval time.Duration) {
+	if g.growAt == 0 {
+		return
+	}
+
+	g.check(counter)
+
+	g.stop, g.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(g.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				g.check(counter)
+			case <-g.wake:
+				g.check(counter)
+			case <-g.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (g *Generator) check(counter models.ShortKeyCounter) {
+	if err := g.CheckUtilisation(counter); err != nil {
+		log.Println("[Error] Could not check short key utilisation ", err)
+	}
+}
+
+// Close stops the background utilisation checks started by Start.
+func (g *Generator) Close() {
+	if g.stop == nil {
+		return
+	}
+	close(g.stop)
+	<-g.done
+}
+
+func (g *Generator) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := g.stats
+	if stats.Generated > 0 {
+		stats.CollisionRate = float64(stats.Collisions) / float64(stats.Generated)
+	}
+	return stats
+}
+
+func keyspaceSize(base, length int) (uint64, error) {
+	if base < 2 || length < 1 {
+		return 0, errors.New("key alphabet needs at least 2 characters and keys at least 1")
+	}
+
+	keyspace := uint64(1)
+	for i := 0; i < length; i++ {
+		if keyspace > (1<<63)/uint64(base) {
+			return 0, fmt.Errorf("keys of length %d do not fit in 63 bits", length)
+		}
+		keyspace *= uint64(base)
+	}
+	return keyspace, nil
+}
+
+func encode(n uint64, alphabet string, length int) string {
+	base := uint64(len(alphabet))
+	key := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		key[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(key)
+}
@@ -0,0 +1,87 @@
+package keygen
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"sync"
+
+	"github.com/Conero007/url-shortener/constants"
+	"github.com/Conero007/url-shortener/models"
+)
+
+type randomStrategy struct {
+	keyspace uint64
+}
+
+func (s randomStrategy) next(url string, attempt int) (uint64, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).SetUint64(s.keyspace))
+	if err != nil {
+		return 0, err
+	}
+	return n.Uint64(), nil
+}
+
+// hashStrategy derives the key from the URL, so the first attempt for a URL
+// always yields the same key. Retries mix in a random salt.
+type hashStrategy struct {
+	keyspace uint64
+}
+
+func (s hashStrategy) next(url string, attempt int) (uint64, error) {
+	input := []byte(url)
+	if attempt > 0 {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return 0, err
+		}
+		input = append(input, salt...)
+	}
+
+	sum := sha256.Sum256(input)
+	n := new(big.Int).SetBytes(sum[:])
+	return n.Mod(n, new(big.Int).SetUint64(s.keyspace)).Uint64(), nil
+}
+
+// counterStrategy numbers keys from a shared sequence and scrambles the
+// numbers with a keyed permutation, so consecutive links do not get
+// consecutive keys. Numbers are reserved from the sequence in blocks.
+type counterStrategy struct {
+	keyspace    uint64
+	permutation *feistel
+	sequence    models.KeySequenceStore
+
+	mu       sync.Mutex
+	current  int64
+	blockEnd int64
+}
+
+func (s *counterStrategy) next(url string, attempt int) (uint64, error) {
+	for {
+		s.mu.Lock()
+		if s.current < s.blockEnd {
+			n := uint64(s.current)
+			s.current++
+			s.mu.Unlock()
+
+			if n >= s.keyspace {
+				return 0, ErrKeyspaceExhausted
+			}
+			return s.permutation.permute(n), nil
+		}
+		s.mu.Unlock()
+
+		// The block is reserved without holding s.mu. When two callers
+		// reserve at once, one block goes unused.
+		start, err := s.sequence.ReserveKeySequence(constants.KEY_SEQUENCE_BLOCK_SIZE)
+		if err != nil {
+			return 0, err
+		}
+
+		s.mu.Lock()
+		if s.current >= s.blockEnd {
+			s.current, s.blockEnd = start, start+constants.KEY_SEQUENCE_BLOCK_SIZE
+		}
+		s.mu.Unlock()
+	}
+}
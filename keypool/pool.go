@@ -0,0 +1,232 @@
+package keypool
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/constants"
+	"github.com/Conero007/url-shortener/keygen"
+	"github.com/Conero007/url-shortener/models"
+)
+
+type Metrics struct {
+	Available          int64      `json:"available"`
+	Refills            int64      `json:"refills"`
+	KeysAdded          int64      `json:"keys_added"`
+	KeysTaken          int64      `json:"keys_taken"`
+	Fallbacks          int64      `json:"fallbacks"`
+	Collisions         int64      `json:"collisions"`
+	LowWatermarkAlerts int64      `json:"low_watermark_alerts"`
+	Errors             int64      `json:"errors"`
+	LastRefillAt       *time.Time `json:"last_refill_at"`
+}
+
+// Pool hands out pre-generated keys that are known to be unused, so creating
+// a link does not depend on guessing a free key. Every instance takes keys
+// from the shared pool in small blocks, and a background refiller tops the
+// pool up to size with keys from the generator. When the pool drops below
+// lowWatermark an alert is logged, and when it runs dry keys are generated
+// directly instead.
+type Pool struct {
+	store        models.Store
+	keys         *keygen.Generator
+	size         int
+	lowWatermark int
+	batchSize    int
+	interval     time.Duration
+
+	mu      sync.Mutex
+	buffer  []string
+	metrics Metrics
+
+	refillMu sync.Mutex
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewPool(store models.Store, keys *keygen.Generator, size, lowWatermark, batchSize int, interval time.Duration) *Pool {
+	p := &Pool{
+		store:        store,
+		keys:         keys,
+		size:         size,
+		lowWatermark: lowWatermark,
+		batchSize:    batchSize,
+		interval:     interval,
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+// GenerateKey returns a key from the pool, claiming a new block when the local
+// one is used up. Pooled keys the generator no longer allows, because they
+// were reserved after being pooled, are dropped.
+func (p *Pool) GenerateKey(url string, attempt int) (string, error) {
+	p.mu.Lock()
+	key, ok := p.pop()
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	keys, err := p.store.TakePooledKeys(constants.KEY_POOL_TAKE_SIZE)
+	if err != nil {
+		log.Println("[Error] Could not take keys from the key pool ", err)
+	}
+	if len(keys) < constants.KEY_POOL_TAKE_SIZE {
+		p.triggerRefill()
+	}
+
+	p.mu.Lock()
+	if err != nil {
+		p.metrics.Errors++
+	}
+	p.metrics.KeysTaken += int64(len(keys))
+	p.buffer = append(p.buffer, keys...)
+	key, ok = p.pop()
+	if !ok {
+		p.metrics.Fallbacks++
+	}
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+	return p.keys.GenerateKey(url, attempt)
+}
+
+// pop returns the next buffered key the generator allows. The caller holds
+// p.mu.
+func (p *Pool) pop() (string, bool) {
+	for len(p.buffer) > 0 {
+		key := p.buffer[len(p.buffer)-1]
+		p.buffer = p.buffer[:len(p.buffer)-1]
+		if p.keys.Allowed(key) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func (p *Pool) RecordCollision(exhausted bool) {
+	p.mu.Lock()
+	p.metrics.Collisions++
+	p.mu.Unlock()
+
+	p.keys.RecordCollision(exhausted)
+}
+
+// Refill tops the pool up to its size and returns the number of keys added.
+func (p *Pool) Refill() (int, error) {
+	p.refillMu.Lock()
+	defer p.refillMu.Unlock()
+
+	available, err := p.store.CountPooledKeys()
+	if err != nil {
+		p.recordRefill(0, 0, err)
+		return 0, err
+	}
+
+	if available < p.lowWatermark {
+		log.Printf("[Error] Key pool below low watermark: %d keys available, %d expected", available, p.lowWatermark)
+		p.mu.Lock()
+		p.metrics.LowWatermarkAlerts++
+		p.mu.Unlock()
+	}
+
+	var added int
+	for available < p.size {
+		n := min(p.batchSize, p.size-available)
+
+		keys := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			// Pooled keys do not belong to a URL yet, so every candidate is
+			// asked for as a retry to get a fresh one from hash generators.
+			key, err := p.keys.GenerateKey("", 1)
+			if err != nil {
+				p.recordRefill(available, added, err)
+				return added, err
+			}
+			keys = append(keys, key)
+		}
+
+		n, err = p.store.AddPooledKeys(keys)
+		if err != nil {
+			p.recordRefill(available, added, err)
+			return added, err
+		}
+		if n == 0 {
+			break
+		}
+		available += n
+		added += n
+	}
+
+	p.recordRefill(available, added, nil)
+	return added, nil
+}
+
+func (p *Pool) recordRefill(available, added int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	refilledAt := time.Now().UTC()
+	p.metrics.Refills++
+	p.metrics.KeysAdded += int64(added)
+	p.metrics.LastRefillAt = &refilledAt
+	if err != nil {
+		p.metrics.Errors++
+		return
+	}
+	p.metrics.Available = int64(available)
+}
+
+func (p *Pool) Metrics() Metrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.metrics
+}
+
+func (p *Pool) triggerRefill() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.refill()
+	for {
+		select {
+		case <-ticker.C:
+			p.refill()
+		case <-p.wake:
+			p.refill()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *Pool) refill() {
+	if _, err := p.Refill(); err != nil {
+		log.Println("[Error] Could not refill key pool ", err)
+	}
+}
+
+func (p *Pool) Close() {
+	close(p.stop)
+	<-p.done
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/Conero007/url-shortener/app"
+	"github.com/Conero007/url-shortener/config"
+	"github.com/Conero007/url-shortener/constants"
+	"github.com/Conero007/url-shortener/database"
+	"github.com/Conero007/url-shortener/ratelimit"
 	"github.com/joho/godotenv"
 )
 
@@ -13,9 +19,19 @@ func main() {
 		log.Fatal(".env file could not be loaded ", err)
 	}
 
+	database.SetMigrationsDir(os.Getenv("MIGRATIONS_DIR"))
+
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	app := app.NewApp(false)
 
 	if err := app.InitializeDB(
+		os.Getenv("DB_DRIVER"),
 		os.Getenv("DB_ADDR"),
 		os.Getenv("DB_USERNAME"),
 		os.Getenv("DB_PASSWORD"),
@@ -24,16 +40,138 @@ func main() {
 		log.Fatal("Failed to initialize DB ", err)
 	}
 
+	if err := app.InitializeAuth(
+		config.GetBool("API_KEY_REQUIRED", true),
+		config.GetBool("TRUST_PROXY_HEADERS", false),
+		config.GetList("TRUSTED_PROXIES"),
+	); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES ", err)
+	}
+	if err := initializeLinks(app); err != nil {
+		log.Fatal(err)
+	}
+	app.InitializeMetrics(os.Getenv("METRICS_TOKEN"))
 	app.InitializeRoutes()
 
-	if err := app.InitializeRedis(
+	if err := app.InitializeCache(
+		os.Getenv("CACHE_DRIVER"),
 		os.Getenv("REDIS_ADDR"),
 		os.Getenv("REDIS_PASSWORD"),
+		config.GetInt("CACHE_SIZE", constants.DEFAULT_CACHE_SIZE),
+		config.GetDuration("CACHE_TTL", constants.DEFAULT_CACHE_TTL),
 	); err != nil {
-		log.Fatal("Failed to initialize redis ", err)
+		log.Fatal("Failed to initialize cache ", err)
+	}
+
+	if config.GetBool("RATE_LIMIT_ENABLED", true) {
+		shortenRate, err := ratelimit.ParseRate(config.GetString("RATE_LIMIT_SHORTEN", constants.DEFAULT_RATE_LIMIT_SHORTEN))
+		if err != nil {
+			log.Fatal("Invalid RATE_LIMIT_SHORTEN ", err)
+		}
+		redirectRate, err := ratelimit.ParseRate(config.GetString("RATE_LIMIT_REDIRECT", constants.DEFAULT_RATE_LIMIT_REDIRECT))
+		if err != nil {
+			log.Fatal("Invalid RATE_LIMIT_REDIRECT ", err)
+		}
+		app.InitializeRateLimiting(shortenRate, redirectRate)
+	}
+
+	if err := app.InitializeJanitor(
+		config.GetDuration("EXPIRY_SWEEP_INTERVAL", constants.DEFAULT_EXPIRY_SWEEP_INTERVAL),
+		config.GetDuration("ARCHIVE_QUARANTINE", constants.DEFAULT_ARCHIVE_QUARANTINE),
+		config.GetInt("EXPIRY_SWEEP_BATCH_SIZE", constants.DEFAULT_EXPIRY_SWEEP_BATCH_SIZE),
+	); err != nil {
+		log.Fatal("Invalid EXPIRY_SWEEP_INTERVAL ", err)
+	}
+
+	if err := app.InitializeKeyPool(
+		config.GetInt("KEY_POOL_SIZE", constants.DEFAULT_KEY_POOL_SIZE),
+		config.GetInt("KEY_POOL_LOW_WATERMARK", constants.DEFAULT_KEY_POOL_LOW_WATERMARK),
+		config.GetInt("KEY_POOL_REFILL_BATCH_SIZE", constants.DEFAULT_KEY_POOL_REFILL_BATCH_SIZE),
+		config.GetDuration("KEY_POOL_REFILL_INTERVAL", constants.DEFAULT_KEY_POOL_REFILL_INTERVAL),
+	); err != nil {
+		log.Fatal("Invalid KEY_POOL_REFILL_BATCH_SIZE or KEY_POOL_REFILL_INTERVAL ", err)
+	}
+
+	if err := app.InitializeClickCounters(
+		config.GetDuration("CLICK_COUNTS_FLUSH_INTERVAL", constants.DEFAULT_CLICK_COUNTS_FLUSH_INTERVAL),
+	); err != nil {
+		log.Fatal("Invalid CLICK_COUNTS_FLUSH_INTERVAL ", err)
+	}
+
+	if config.GetBool("ANALYTICS_ENABLED", true) {
+		if err := app.InitializeAnalytics(
+			config.GetInt("ANALYTICS_BUFFER_SIZE", constants.DEFAULT_ANALYTICS_BUFFER_SIZE),
+			config.GetInt("ANALYTICS_BATCH_SIZE", constants.DEFAULT_ANALYTICS_BATCH_SIZE),
+			config.GetDuration("ANALYTICS_FLUSH_INTERVAL", constants.DEFAULT_ANALYTICS_FLUSH_INTERVAL),
+			config.GetString("COUNTRY_HEADER", constants.DEFAULT_COUNTRY_HEADER),
+			os.Getenv("COUNTRY_DATABASE"),
+		); err != nil {
+			log.Fatal("Invalid ANALYTICS_FLUSH_INTERVAL ", err)
+		}
 	}
 
 	if err := app.Run(":" + os.Getenv("PORT")); err != nil {
 		log.Fatal("Failed to Run the APP ", err)
 	}
 }
+
+// initializeLinks applies the settings for creating links, shared by the
+// server and the links command.
+func initializeLinks(a *app.AppConfig) error {
+	a.InitializeLinkExpiry(config.GetDuration("MAX_LINK_TTL", constants.DEFAULT_MAX_LINK_TTL))
+	a.InitializeBatchShortening(config.GetInt("BATCH_MAX_ITEMS", constants.DEFAULT_BATCH_MAX_ITEMS))
+
+	if config.GetBool("STRIP_TRACKING_PARAMS", false) {
+		a.InitializeURLNormalization(strings.Split(config.GetString("TRACKING_PARAMS", constants.DEFAULT_TRACKING_PARAMS), ","))
+	}
+
+	var shortenerDomains []string
+	if config.GetBool("BLOCK_CHAINED_SHORTENERS", true) {
+		shortenerDomains = strings.Split(config.GetString("SHORTENER_DOMAINS", constants.DEFAULT_SHORTENER_DOMAINS), ",")
+	}
+	a.InitializeURLSafety(
+		strings.Split(config.GetString("ALLOWED_URL_SCHEMES", constants.DEFAULT_URL_SCHEMES), ","),
+		config.GetList("BLOCKED_DOMAINS"),
+		config.GetList("ALLOWED_DOMAINS"),
+		shortenerDomains,
+		[]string{os.Getenv("APP_URL")},
+	)
+
+	a.InitializeDeduplication(
+		config.GetBool("DEDUPE_LINKS", false),
+		config.GetDuration("IDEMPOTENCY_KEY_TTL", constants.DEFAULT_IDEMPOTENCY_KEY_TTL),
+	)
+
+	if err := a.InitializeRedirects(
+		config.GetInt("REDIRECT_TYPE", constants.DEFAULT_REDIRECT_TYPE),
+		config.GetDuration("PERMANENT_REDIRECT_MAX_AGE", constants.DEFAULT_PERMANENT_REDIRECT_MAX_AGE),
+	); err != nil {
+		return fmt.Errorf("invalid REDIRECT_TYPE: %w", err)
+	}
+
+	if err := a.InitializeKeyGenerator(
+		config.GetString("KEY_GENERATOR", constants.DEFAULT_KEY_GENERATOR),
+		config.GetString("KEY_ALPHABET", constants.DEFAULT_KEY_ALPHABET),
+		config.GetInt("SHORT_KEY_LENGTH", constants.DEFAULT_SHORT_KEY_LENGTH),
+		config.GetInt("KEY_LENGTH_GROWTH_PERCENT", constants.DEFAULT_KEY_LENGTH_GROWTH_PERCENT),
+		os.Getenv("KEY_GENERATOR_SECRET"),
+	); err != nil {
+		return fmt.Errorf("invalid key generator settings: %w", err)
+	}
+
+	a.InitializeReservedKeys(
+		config.GetList("RESERVED_KEYS"),
+		config.GetList("BLOCKED_WORDS"),
+		config.GetList("ALLOWED_WORDS"),
+		config.GetBool("PROFANITY_FILTER", true),
+	)
+
+	if err := a.InitializeCustomKeys(
+		config.GetInt("CUSTOM_KEY_MIN_LENGTH", constants.DEFAULT_CUSTOM_KEY_MIN_LENGTH),
+		config.GetInt("CUSTOM_KEY_MAX_LENGTH", constants.DEFAULT_CUSTOM_KEY_MAX_LENGTH),
+	); err != nil {
+		return fmt.Errorf("invalid CUSTOM_KEY_MIN_LENGTH or CUSTOM_KEY_MAX_LENGTH: %w", err)
+	}
+
+	return nil
+}
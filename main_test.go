@@ -3,23 +3,34 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Conero007/url-shortener/app"
+	"github.com/Conero007/url-shortener/cache"
+	"github.com/Conero007/url-shortener/config"
 	"github.com/Conero007/url-shortener/constants"
 	"github.com/Conero007/url-shortener/database"
+	"github.com/Conero007/url-shortener/models"
+	"github.com/Conero007/url-shortener/ratelimit"
+	"github.com/Conero007/url-shortener/storage"
 	"github.com/joho/godotenv"
 )
 
 var TestApp *app.AppConfig
+var testAPIKey string
 
 func TestMain(m *testing.M) {
 	if err := godotenv.Load(".testing.env"); err != nil {
@@ -29,6 +40,7 @@ func TestMain(m *testing.M) {
 	TestApp = app.NewApp(true)
 
 	if err := TestApp.InitializeDB(
+		os.Getenv("DB_DRIVER"),
 		os.Getenv("DB_ADDR"),
 		os.Getenv("DB_USERNAME"),
 		os.Getenv("DB_PASSWORD"),
@@ -37,156 +49,1625 @@ func TestMain(m *testing.M) {
 		log.Fatal("Failed to initialize db ", err)
 	}
 
+	if err := TestApp.InitializeAuth(
+		config.GetBool("API_KEY_REQUIRED", true),
+		config.GetBool("TRUST_PROXY_HEADERS", false),
+		config.GetList("TRUSTED_PROXIES"),
+	); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES ", err)
+	}
+	if err := initializeLinks(TestApp); err != nil {
+		log.Fatal(err)
+	}
+	TestApp.InitializeMetrics(os.Getenv("METRICS_TOKEN"))
 	TestApp.InitializeRoutes()
 
-	if err := TestApp.InitializeRedis(
-		os.Getenv("REDIS_ADDR"),
-		os.Getenv("REDIS_PASSWORD"),
-	); err != nil {
-		log.Fatal("Failed to initialize redis ", err)
+	if err := TestApp.InitializeCache(
+		os.Getenv("CACHE_DRIVER"),
+		os.Getenv("REDIS_ADDR"),
+		os.Getenv("REDIS_PASSWORD"),
+		config.GetInt("CACHE_SIZE", constants.DEFAULT_CACHE_SIZE),
+		config.GetDuration("CACHE_TTL", constants.DEFAULT_CACHE_TTL),
+	); err != nil {
+		log.Fatal("Failed to initialize cache ", err)
+	}
+
+	if config.GetBool("RATE_LIMIT_ENABLED", true) {
+		shortenRate, err := ratelimit.ParseRate(config.GetString("RATE_LIMIT_SHORTEN", constants.DEFAULT_RATE_LIMIT_SHORTEN))
+		if err != nil {
+			log.Fatal("Invalid RATE_LIMIT_SHORTEN ", err)
+		}
+		redirectRate, err := ratelimit.ParseRate(config.GetString("RATE_LIMIT_REDIRECT", constants.DEFAULT_RATE_LIMIT_REDIRECT))
+		if err != nil {
+			log.Fatal("Invalid RATE_LIMIT_REDIRECT ", err)
+		}
+		TestApp.InitializeRateLimiting(shortenRate, redirectRate)
+	}
+
+	if err := TestApp.InitializeJanitor(
+		config.GetDuration("EXPIRY_SWEEP_INTERVAL", constants.DEFAULT_EXPIRY_SWEEP_INTERVAL),
+		config.GetDuration("ARCHIVE_QUARANTINE", constants.DEFAULT_ARCHIVE_QUARANTINE),
+		config.GetInt("EXPIRY_SWEEP_BATCH_SIZE", constants.DEFAULT_EXPIRY_SWEEP_BATCH_SIZE),
+	); err != nil {
+		log.Fatal("Invalid EXPIRY_SWEEP_INTERVAL ", err)
+	}
+
+	if err := TestApp.InitializeKeyPool(
+		config.GetInt("KEY_POOL_SIZE", constants.DEFAULT_KEY_POOL_SIZE),
+		config.GetInt("KEY_POOL_LOW_WATERMARK", constants.DEFAULT_KEY_POOL_LOW_WATERMARK),
+		config.GetInt("KEY_POOL_REFILL_BATCH_SIZE", constants.DEFAULT_KEY_POOL_REFILL_BATCH_SIZE),
+		config.GetDuration("KEY_POOL_REFILL_INTERVAL", constants.DEFAULT_KEY_POOL_REFILL_INTERVAL),
+	); err != nil {
+		log.Fatal("Invalid KEY_POOL_REFILL_BATCH_SIZE or KEY_POOL_REFILL_INTERVAL ", err)
+	}
+
+	if err := TestApp.InitializeClickCounters(
+		config.GetDuration("CLICK_COUNTS_FLUSH_INTERVAL", constants.DEFAULT_CLICK_COUNTS_FLUSH_INTERVAL),
+	); err != nil {
+		log.Fatal("Invalid CLICK_COUNTS_FLUSH_INTERVAL ", err)
+	}
+
+	if config.GetBool("ANALYTICS_ENABLED", true) {
+		if err := TestApp.InitializeAnalytics(
+			config.GetInt("ANALYTICS_BUFFER_SIZE", constants.DEFAULT_ANALYTICS_BUFFER_SIZE),
+			config.GetInt("ANALYTICS_BATCH_SIZE", constants.DEFAULT_ANALYTICS_BATCH_SIZE),
+			config.GetDuration("ANALYTICS_FLUSH_INTERVAL", constants.DEFAULT_ANALYTICS_FLUSH_INTERVAL),
+			config.GetString("COUNTRY_HEADER", constants.DEFAULT_COUNTRY_HEADER),
+			os.Getenv("COUNTRY_DATABASE"),
+		); err != nil {
+			log.Fatal("Invalid ANALYTICS_FLUSH_INTERVAL ", err)
+		}
+	}
+
+	apiKey, key, err := models.NewAPIKey("testing")
+	if err != nil {
+		log.Fatal("Failed to generate API key ", err)
+	}
+	if err := TestApp.Store.InsertAPIKey(apiKey); err != nil {
+		log.Fatal("Failed to store API key ", err)
+	}
+	testAPIKey = key
+
+	m.Run()
+
+	if s, ok := TestApp.Store.(*storage.SQLStore); ok {
+		if _, err := database.MigrateDown(s.DB, s.Dialect.Name, math.MaxInt); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func TestCreateShortenURL(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	var jsonStr = []byte(`{"url":"https://www.google.com/"}`)
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if !validateShortenAPIResponse(t, m) {
+		return
+	}
+}
+
+func TestCreateShortenURLWithEmptyPayload(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{}`)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	var m map[string]string
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["error"] != "URL not given" {
+		t.Errorf("Expected the 'error' key of the response to be set to 'URL not given'. Got '%s'", m["error"])
+	}
+}
+
+func TestCreateShortenURLWithInvalidURL(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url":"google.com"}`)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	var m map[string]string
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["error"] != "Invalid URL given" {
+		t.Errorf("Expected the 'error' key of the response to be set to 'Invalid URL given'. Got '%s'", m["error"])
+	}
+}
+
+func TestCreateShortenURLWithLongURL(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	// The second URL is short enough, but not once its host is converted to
+	// punycode and its path escaped.
+	for _, longURL := range []string{
+		"https://www.google.com/" + strings.Repeat("a", 240),
+		"https://bücher.example/" + strings.Repeat("é", 60),
+	} {
+		response := sendRequesttoShortenAPI(`{"url": "` + longURL + `"}`)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+		var m map[string]string
+		json.Unmarshal(response.Body.Bytes(), &m)
+		if m["code"] != "url_too_long" {
+			t.Errorf("Expected the 'code' key of the response to be set to 'url_too_long'. Got '%s'", m["code"])
+		}
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://bücher.example/` + strings.Repeat("é", 20) + `"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
+
+func TestCreateShortenURLWithCustomURL(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url":"https://www.google.com/", "custom_short_key": "654321"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if !validateShortenAPIResponse(t, m) {
+		return
+	}
+
+	shortURL := m["short_url"].(string)
+	shortKey := shortURL[len(shortURL)-constants.DEFAULT_SHORT_KEY_LENGTH:]
+
+	if shortKey != "654321" {
+		t.Errorf("Expected short key to be (654321), got %s", shortKey)
+	}
+}
+
+func TestCreateShortenURLWithInvalidCustomURL_SpecialCharValidation(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url":"https://www.google.com/", "custom_short_key": "65#321"}`)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	var m map[string]string
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["error"] != "Invalid custom short key" {
+		t.Errorf("Expected the 'error' key of the response to be set to 'Invalid custom short key'. Got '%s'", m["error"])
+	}
+}
+
+func TestCreateShortenURLWithInvalidCustomURL_LengthValidation(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	for _, shortKey := range []string{"abc", "launch2026launch2026x"} {
+		response := sendRequesttoShortenAPI(`{"url":"https://www.google.com/", "custom_short_key": "` + shortKey + `"}`)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+		var m map[string]string
+		json.Unmarshal(response.Body.Bytes(), &m)
+		if m["error"] != "Invalid custom short key" {
+			t.Errorf("Expected the 'error' key of the response to be set to 'Invalid custom short key'. Got '%s'", m["error"])
+		}
+	}
+
+	response := sendRequesttoShortenAPI(`{"url":"https://www.google.com/", "custom_short_key": "launch2026"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
+
+func TestCreateShortenURLWithoutAPIKey(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url":"https://www.google.com/"}`))
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	req, _ = http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url":"https://www.google.com/"}`))
+	req.Header.Set("Authorization", "Bearer us_not_a_real_key")
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	var m map[string]string
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["error"] != "Invalid API key" {
+		t.Errorf("Expected the 'error' key of the response to be set to 'Invalid API key'. Got '%s'", m["error"])
+	}
+}
+
+func TestCreateShortenURLWithRevokedAPIKey(t *testing.T) {
+	apiKey, key, err := models.NewAPIKey("revoked")
+	if err != nil {
+		t.Errorf("Failed to generate API key. ERROR: %s", err.Error())
+		return
+	}
+	if err := TestApp.Store.InsertAPIKey(apiKey); err != nil {
+		t.Errorf("Failed to store API key. ERROR: %s", err.Error())
+		return
+	}
+	if err := TestApp.Store.RevokeAPIKey(apiKey.ID); err != nil {
+		t.Errorf("Failed to revoke API key. ERROR: %s", err.Error())
+		return
+	}
+
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url":"https://www.google.com/"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestBatchShortening(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	payload := `[
+		{"url": "https://www.google.com/", "custom_short_key": "batch1"},
+		{"url": "not a url"},
+		{"url": "https://www.example.com/", "custom_short_key": "batch1"},
+		{"url": "https://www.example.com/", "tags": ["docs"]}
+	]`
+	m := sendRequestToBatchAPI(t, payload)
+
+	if m.Created != 2 || m.Failed != 2 {
+		t.Errorf("Expected 2 created and 2 failed items. Got %d and %d", m.Created, m.Failed)
+	}
+
+	for i, expected := range []int{http.StatusCreated, http.StatusBadRequest, http.StatusNotAcceptable, http.StatusCreated} {
+		if m.Results[i].Index != i || m.Results[i].Status != expected {
+			t.Errorf("Expected item %d to have status %d. Got %+v", i, expected, m.Results[i])
+		}
+	}
+
+	if fetchOriginalURL("batch1") != "https://www.google.com/" {
+		t.Error("Expected the first item to own the custom short key")
+	}
+
+	req, _ := http.NewRequest("GET", strings.TrimPrefix(m.Results[3].ShortURL, fmt.Sprintf("http://%s:%s", os.Getenv("APP_URL"), os.Getenv("PORT"))), nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusFound, response.Code)
+}
+
+func TestBatchShorteningNDJSON(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	payload := `{"url": "https://www.google.com/", "custom_short_key": "ndjsn1"}
+{"url": "https://www.google.com/", "custom_short_key":
+
+{"url": "https://www.example.com/", "ttl": "1h"}
+`
+	m := sendRequestToBatchAPI(t, payload)
+
+	for i, expected := range []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated} {
+		if m.Results[i].Status != expected {
+			t.Errorf("Expected item %d to have status %d. Got %+v", i, expected, m.Results[i])
+		}
+	}
+
+	item := `{"url": "https://www.google.com/"}`
+	for _, items := range []string{
+		strings.Repeat(item+"\n", constants.DEFAULT_BATCH_MAX_ITEMS+1),
+		"[" + strings.Repeat(item+",", constants.DEFAULT_BATCH_MAX_ITEMS*2) + item + "]",
+		"[" + strings.Repeat(" ", (constants.DEFAULT_BATCH_MAX_ITEMS+1)*constants.MAX_BATCH_ITEM_BYTES) + item + "]",
+	} {
+		req, _ := http.NewRequest("POST", "/shorten/batch", bytes.NewBufferString(items))
+		req.Header.Set("Authorization", "Bearer "+testAPIKey)
+		response := executeRequest(req)
+		checkResponseCode(t, http.StatusRequestEntityTooLarge, response.Code)
+	}
+
+	req, _ := http.NewRequest("POST", "/shorten/batch", bytes.NewBufferString(strings.Repeat(" ", (constants.DEFAULT_BATCH_MAX_ITEMS+1)*constants.MAX_BATCH_ITEM_BYTES+1)))
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	req.Header.Set("Idempotency-Key", "oversized-batch")
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusRequestEntityTooLarge, response.Code)
+}
+
+func TestShortenURLRecordsOwningAPIKey(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "owned1"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	u := models.ShortenURL{ShortKey: "owned1"}
+	if err := u.FetchShortURLData(TestApp.Store); err != nil {
+		t.Errorf("Failed to fetch short url from the store. ERROR: %s", err.Error())
+		return
+	}
+
+	apiKey, _ := TestApp.Store.FetchAPIKeyByHash(models.HashAPIKey(testAPIKey))
+	if apiKey == nil || u.APIKeyID != apiKey.ID {
+		t.Errorf("Expected the short url to be owned by the testing API key. Got owner %d", u.APIKeyID)
+	}
+}
+
+func TestShortenURLDedupe(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/dedupe", "dedupe": true}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var created map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &created)
+
+	response = sendRequesttoShortenAPI(`{"url": "HTTPS://WWW.Google.com/dedupe", "dedupe": true}`)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var existing map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &existing)
+	if existing["short_url"] != created["short_url"] {
+		t.Errorf("Expected the existing link %v to be returned. Got %v", created["short_url"], existing["short_url"])
+	}
+
+	response = sendRequesttoShortenAPI(`{"url": "https://www.google.com/dedupe"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	shortURL := created["short_url"].(string)
+	shortKey := shortURL[len(shortURL)-constants.DEFAULT_SHORT_KEY_LENGTH:]
+	response = sendRequestToLinksAPI("PATCH", shortKey, `{"disabled": true}`, testAPIKey)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	result := sendRequestToBatchAPI(t, `[{"url": "https://www.google.com/dedupe", "dedupe": true}, {"url": "https://www.example.com/dedupe", "dedupe": true}, {"url": "HTTPS://www.example.com/dedupe", "dedupe": true}]`)
+	if result.Existing != 2 || result.Created != 1 {
+		t.Errorf("Expected 2 existing and 1 created link. Got %d existing and %d created", result.Existing, result.Created)
+	}
+	if len(result.Results) > 0 && result.Results[0].ShortURL == shortURL {
+		t.Error("Expected the disabled link not to be returned")
+	}
+	if len(result.Results) == 3 && (result.Results[2].Status != http.StatusOK || result.Results[2].ShortURL != result.Results[1].ShortURL) {
+		t.Errorf("Expected the last item to reuse the link created earlier in the batch. Got %+v", result.Results)
+	}
+
+	result = sendRequestToBatchAPI(t, `[{"url": "https://www.example.com/options", "tags": ["a"]}, {"url": "https://www.example.com/options", "tags": ["b"], "dedupe": true}]`)
+	if result.Created != 2 {
+		t.Errorf("Expected items with different tags to get their own links. Got %+v", result.Results)
+	}
+
+	expireTime := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	response = sendRequesttoShortenAPI(`{"url": "https://www.google.com/options", "tags": ["a", "b"], "redirect_type": 301, "expire_time": "` + expireTime + `", "dedupe": true}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	for _, test := range []struct {
+		options  string
+		expected int
+	}{
+		{`"tags": ["b", "a"], "redirect_type": 301, "expire_time": "` + expireTime + `"`, http.StatusOK},
+		{`"tags": ["a"], "redirect_type": 301, "expire_time": "` + expireTime + `"`, http.StatusCreated},
+		{`"tags": ["a", "b"], "expire_time": "` + expireTime + `"`, http.StatusCreated},
+		{`"tags": ["a", "b"], "redirect_type": 301, "ttl": "1h"`, http.StatusCreated},
+	} {
+		response = sendRequesttoShortenAPI(`{"url": "https://www.google.com/options", "dedupe": true, ` + test.options + `}`)
+		checkResponseCode(t, test.expected, response.Code)
+	}
+}
+
+func TestShortenURLNormalization(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	TestApp.InitializeURLNormalization([]string{"utm_*", "fbclid"})
+	defer TestApp.InitializeURLNormalization(nil)
+
+	response := sendRequesttoShortenAPI(`{"url": "HTTPS://Example.com:443/a/../b?utm_source=mail&id=1", "dedupe": true}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var created map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &created)
+	if created["original_url"] != "HTTPS://Example.com:443/a/../b?utm_source=mail&id=1" {
+		t.Errorf("Expected the original URL to be kept. Got %v", created["original_url"])
+	}
+
+	response = sendRequesttoShortenAPI(`{"url": "https://example.com/b?id=1&fbclid=abc", "dedupe": true}`)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var existing map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &existing)
+	if existing["short_url"] != created["short_url"] {
+		t.Errorf("Expected equivalent URLs to share a link. Got %v and %v", created["short_url"], existing["short_url"])
+	}
+
+	response = sendRequesttoShortenAPI(`{"url": "http://bücher.example/"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	if links := listLinks(t, "domain=xn--bcher-kva.example"); len(links.Links) != 1 {
+		t.Errorf("Expected the internationalized domain to be stored as punycode. Got %d links", len(links.Links))
+	}
+	if links := listLinks(t, "domain=B%C3%BCcher.example"); len(links.Links) != 1 {
+		t.Errorf("Expected the domain filter to be normalized. Got %d links", len(links.Links))
+	}
+
+	response = sendRequesttoShortenAPI(`{"url": "http://-bücher.example/"}`)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestShortenURLIdempotencyKey(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	shorten := func(payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(payload))
+		req.Header.Set("Authorization", "Bearer "+testAPIKey)
+		req.Header.Set("Idempotency-Key", "retry-0001")
+		return executeRequest(req)
+	}
+
+	response := shorten(`{"url": "https://www.google.com/retry"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	first := response.Body.String()
+
+	response = shorten(`{"url": "https://www.google.com/retry"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	if response.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected the response to be replayed")
+	}
+	if response.Body.String() != first {
+		t.Errorf("Expected the replayed response to match. Got '%s' and '%s'", first, response.Body.String())
+	}
+
+	if links := listLinks(t, ""); len(links.Links) != 1 {
+		t.Errorf("Expected a single link to be created. Got %d", len(links.Links))
+	}
+
+	response = shorten(`{"url": "https://www.example.com/retry"}`)
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+}
+
+func TestKeyGeneratorStrategies(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+	defer restoreLinkSettings(t)
+
+	if err := TestApp.InitializeKeyGenerator("md5", constants.DEFAULT_KEY_ALPHABET, constants.DEFAULT_SHORT_KEY_LENGTH, 0, ""); err == nil {
+		t.Error("Expected an unknown key generator to be rejected")
+	}
+	if err := TestApp.InitializeKeyGenerator("counter", constants.DEFAULT_KEY_ALPHABET, constants.DEFAULT_SHORT_KEY_LENGTH, 0, ""); err == nil {
+		t.Error("Expected the counter key generator to be rejected without a secret")
+	}
+
+	shortKeys := map[string]bool{}
+	for _, strategy := range []string{"random", "counter", "hash"} {
+		if err := TestApp.InitializeKeyGenerator(strategy, constants.DEFAULT_KEY_ALPHABET, constants.DEFAULT_SHORT_KEY_LENGTH, 0, "secret"); err != nil {
+			t.Errorf("Failed to initialize %s key generator. ERROR: %s", strategy, err.Error())
+			continue
+		}
+
+		result := sendRequestToBatchAPI(t, fmt.Sprintf(`[{"url": "https://www.google.com/%[1]s/1"}, {"url": "https://www.google.com/%[1]s/2"}]`, strategy))
+		if result.Created != 2 {
+			t.Errorf("Expected the %s key generator to create 2 links. Got %d", strategy, result.Created)
+		}
+
+		response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/` + strategy + `/1"}`)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var m map[string]interface{}
+		json.Unmarshal(response.Body.Bytes(), &m)
+		if len(result.Results) != 2 {
+			continue
+		}
+		for _, shortURL := range []string{result.Results[0].ShortURL, result.Results[1].ShortURL, fmt.Sprint(m["short_url"])} {
+			if shortKeys[shortURL] {
+				t.Errorf("Expected the %s key generator to generate unique keys. Got %s twice", strategy, shortURL)
+			}
+			shortKeys[shortURL] = true
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("METRICS_TOKEN"))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var metrics app.MetricsResponse
+	json.Unmarshal(response.Body.Bytes(), &metrics)
+	if metrics.KeyGenerator.Strategy != "hash" || metrics.KeyGenerator.Generated != 4 || metrics.KeyGenerator.Collisions != 1 {
+		t.Errorf("Expected the hash key generator to report 4 keys and 1 collision. Got %+v", metrics.KeyGenerator)
+	}
+}
+
+func TestShortKeyLength(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+	defer TestApp.InitializeCustomKeys(constants.DEFAULT_CUSTOM_KEY_MIN_LENGTH, constants.DEFAULT_CUSTOM_KEY_MAX_LENGTH)
+	defer restoreLinkSettings(t)
+
+	if err := TestApp.InitializeKeyGenerator("random", "base58", 6, 0, ""); err == nil {
+		t.Error("Expected an unknown key alphabet to be rejected")
+	}
+	if err := TestApp.InitializeCustomKeys(8, 4); err == nil {
+		t.Error("Expected a custom key minimum above the maximum to be rejected")
+	}
+
+	if err := TestApp.InitializeCustomKeys(1, 8); err != nil {
+		t.Errorf("Failed to set custom key lengths. ERROR: %s", err.Error())
+		return
+	}
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "a"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	// One of the 58 keys of length 1 is taken, which is above the 1% growth
+	// threshold, so generated keys start at length 2.
+	if err := TestApp.InitializeKeyGenerator("random", "unambiguous", 1, 1, ""); err != nil {
+		t.Errorf("Failed to initialize key generator. ERROR: %s", err.Error())
+		return
+	}
+
+	for i := 0; i < 5; i++ {
+		response = sendRequesttoShortenAPI(fmt.Sprintf(`{"url": "https://www.google.com/%d"}`, i))
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var m map[string]interface{}
+		json.Unmarshal(response.Body.Bytes(), &m)
+		shortURL := fmt.Sprint(m["short_url"])
+		shortKey := shortURL[strings.LastIndex(shortURL, "/")+1:]
+		if len(shortKey) != 2 || strings.ContainsAny(shortKey, "0O1l") {
+			t.Errorf("Expected a 2 character key without ambiguous characters. Got '%s'", shortKey)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("METRICS_TOKEN"))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var metrics app.MetricsResponse
+	json.Unmarshal(response.Body.Bytes(), &metrics)
+	if metrics.KeyGenerator.KeyLength != 2 {
+		t.Errorf("Expected the key generator to report key length 2. Got %d", metrics.KeyGenerator.KeyLength)
+	}
+}
+
+func TestReservedShortKeys(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+	defer TestApp.InitializeReservedKeys(nil, nil, nil, true)
+	defer restoreLinkSettings(t)
+
+	rejected := map[string]string{
+		"admin":  "Short key is reserved",
+		"Health": "Short key is reserved",
+		"sh1tty": "Short key contains a blocked word",
+	}
+	for shortKey, message := range rejected {
+		response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "` + shortKey + `"}`)
+		checkResponseCode(t, http.StatusNotAcceptable, response.Code)
+
+		var m map[string]string
+		json.Unmarshal(response.Body.Bytes(), &m)
+		if m["error"] != message {
+			t.Errorf("Expected the 'error' key of the response to be set to '%s'. Got '%s'", message, m["error"])
+		}
+	}
+
+	// Ordinary words that contain a profane one are accepted.
+	for _, shortKey := range []string{"grapes", "Drapes", "therapy", "scrapes"} {
+		response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "` + shortKey + `"}`)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	}
+
+	TestApp.InitializeReservedKeys([]string{"promo"}, []string{"acme"}, []string{"acmecorp"}, false)
+	for _, shortKey := range []string{"promo", "acmeco", "acmecorpacme"} {
+		response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "` + shortKey + `"}`)
+		checkResponseCode(t, http.StatusNotAcceptable, response.Code)
+	}
+	for _, shortKey := range []string{"sh1tty", "acmecorp1"} {
+		response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "` + shortKey + `"}`)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	}
+
+	// With every letter reserved, single character keys can only be digits.
+	var letters []string
+	for c := 'a'; c <= 'z'; c++ {
+		letters = append(letters, string(c))
+	}
+	TestApp.InitializeReservedKeys(letters, nil, nil, true)
+	if err := TestApp.InitializeKeyGenerator("random", "unambiguous", 1, 0, ""); err != nil {
+		t.Errorf("Failed to initialize key generator. ERROR: %s", err.Error())
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		response := sendRequesttoShortenAPI(fmt.Sprintf(`{"url": "https://www.google.com/%d"}`, i))
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var m map[string]interface{}
+		json.Unmarshal(response.Body.Bytes(), &m)
+		shortURL := fmt.Sprint(m["short_url"])
+		if shortKey := shortURL[strings.LastIndex(shortURL, "/")+1:]; !strings.ContainsAny(shortKey, "23456789") {
+			t.Errorf("Expected a generated key without reserved letters. Got '%s'", shortKey)
+		}
+	}
+}
+
+func TestURLSafety(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+	defaultSchemes := strings.Split(constants.DEFAULT_URL_SCHEMES, ",")
+	defer TestApp.InitializeURLSafety(defaultSchemes, nil, nil, strings.Split(constants.DEFAULT_SHORTENER_DOMAINS, ","), []string{os.Getenv("APP_URL")})
+
+	checkRejected := func(rawURL string, status int, code string) {
+		t.Helper()
+		response := sendRequesttoShortenAPI(`{"url": "` + rawURL + `"}`)
+		checkResponseCode(t, status, response.Code)
+
+		var m map[string]string
+		json.Unmarshal(response.Body.Bytes(), &m)
+		if m["code"] != code {
+			t.Errorf("Expected %s to be rejected with code '%s'. Got '%s'", rawURL, code, m["code"])
+		}
+	}
+
+	checkRejected("javascript:alert(1)", http.StatusBadRequest, "scheme_not_allowed")
+	checkRejected("data:text/html,hello", http.StatusBadRequest, "scheme_not_allowed")
+	checkRejected("ftp://example.com/file", http.StatusBadRequest, "scheme_not_allowed")
+	checkRejected(fmt.Sprintf("http://%s:%s/abc123", strings.ToUpper(os.Getenv("APP_URL")), os.Getenv("PORT")), http.StatusBadRequest, "self_reference")
+	checkRejected("https://bit.ly/abc123", http.StatusBadRequest, "chained_shortener")
+	checkRejected("https://www.tinyurl.com/abc123", http.StatusBadRequest, "chained_shortener")
+
+	result := sendRequestToBatchAPI(t, `[{"url": "file:///etc/passwd"}, {"url": "https://www.google.com/"}]`)
+	if result.Created != 1 || len(result.Results) != 2 || result.Results[0].Code != "scheme_not_allowed" {
+		t.Errorf("Expected the batch to reject the file URL with code 'scheme_not_allowed'. Got %+v", result)
+	}
+
+	TestApp.InitializeURLSafety(defaultSchemes, []string{"*.evil.com"}, nil, nil, []string{os.Getenv("APP_URL")})
+	checkRejected("https://evil.com/", http.StatusForbidden, "domain_blocked")
+	checkRejected("https://login.EVIL.com/", http.StatusForbidden, "domain_blocked")
+	response := sendRequesttoShortenAPI(`{"url": "https://bit.ly/abc123"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	TestApp.InitializeURLSafety(defaultSchemes, nil, []string{"*.example.com"}, nil, []string{os.Getenv("APP_URL")})
+	checkRejected("https://www.google.com/", http.StatusForbidden, "domain_not_allowed")
+	response = sendRequesttoShortenAPI(`{"url": "https://docs.example.com/"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
+
+func TestKeyspaceExhausted(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+	defer TestApp.InitializeReservedKeys(nil, nil, nil, true)
+	defer restoreLinkSettings(t)
+
+	// Reserving every letter and digit leaves no single character key.
+	var keys []string
+	for _, c := range "abcdefghijklmnopqrstuvwxyz0123456789" {
+		keys = append(keys, string(c))
+	}
+	TestApp.InitializeReservedKeys(keys, nil, nil, true)
+	if err := TestApp.InitializeKeyGenerator("random", "base62", 1, 0, ""); err != nil {
+		t.Errorf("Failed to initialize key generator. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/"}`)
+	checkResponseCode(t, http.StatusServiceUnavailable, response.Code)
+
+	var m map[string]string
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["code"] != "no_key_available" {
+		t.Errorf("Expected the 'code' key of the response to be set to 'no_key_available'. Got '%s'", m["code"])
+	}
+
+	result := sendRequestToBatchAPI(t, `[{"url": "https://www.google.com/"}]`)
+	if len(result.Results) != 1 || result.Results[0].Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected the batch item to fail with status 503. Got %+v", result)
+	}
+}
+
+func TestKeyPool(t *testing.T) {
+	for _, table := range []string{"urls", "key_pool"} {
+		if err := clearData(table); err != nil {
+			t.Errorf("Could not clear %s table. ERROR: %s", table, err.Error())
+			return
+		}
+	}
+
+	if err := TestApp.InitializeKeyPool(50, 10, 20, 0); err == nil {
+		t.Error("Expected a key pool without a refill interval to be rejected")
+	}
+	if err := TestApp.InitializeKeyPool(50, 10, 20, time.Hour); err != nil {
+		t.Errorf("Failed to initialize key pool. ERROR: %s", err.Error())
+		return
+	}
+	defer TestApp.InitializeKeyPool(0, 0, 0, 0)
+
+	metrics := func() app.MetricsResponse {
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Authorization", "Bearer "+os.Getenv("METRICS_TOKEN"))
+		response := executeRequest(req)
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var m app.MetricsResponse
+		json.Unmarshal(response.Body.Bytes(), &m)
+		return m
+	}
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if m := metrics(); m.KeyPool != nil && m.KeyPool.Available == 50 {
+			break
+		}
+	}
+
+	if m := metrics(); m.KeyPool == nil || m.KeyPool.Available != 50 || m.KeyPool.LowWatermarkAlerts == 0 {
+		t.Errorf("Expected the empty pool to raise an alert and be filled with 50 keys. Got %+v", m.KeyPool)
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/pooled"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	if count, _ := TestApp.Store.CountPooledKeys(); count != 30 {
+		t.Errorf("Expected a block of 20 keys to be taken from the pool. Got %d keys left", count)
+	}
+	if result := sendRequestToBatchAPI(t, `[{"url": "https://www.google.com/pooled/1"}, {"url": "https://www.google.com/pooled/2"}]`); result.Created != 2 {
+		t.Errorf("Expected 2 links to be created from pooled keys. Got %d", result.Created)
+	}
+	if m := metrics(); m.KeyPool.KeysTaken != 20 || m.KeyPool.Fallbacks != 0 {
+		t.Errorf("Expected the keys to come from the pool. Got %+v", m.KeyPool)
+	}
+
+	if added, _ := TestApp.Store.AddPooledKeys([]string{"poolky"}); added != 1 {
+		t.Error("Expected an unused key to be added to the pool")
+	}
+
+	response = sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "poolky"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	if added, _ := TestApp.Store.AddPooledKeys([]string{"poolky"}); added != 0 {
+		t.Error("Expected a used key not to be added to the pool")
+	}
+	if count, _ := TestApp.Store.CountPooledKeys(); count != 30 {
+		t.Errorf("Expected the custom key to be removed from the pool. Got %d keys left", count)
+	}
+
+	// Claimed keys are no longer available, and a refill does not add them
+	// back while the instance that claimed them may still use them.
+	claimed, err := TestApp.Store.TakePooledKeys(2)
+	if err != nil || len(claimed) != 2 {
+		t.Errorf("Expected 2 keys to be claimed from the pool. Got %v, %v", claimed, err)
+		return
+	}
+	if added, _ := TestApp.Store.AddPooledKeys(claimed); added != 0 {
+		t.Errorf("Expected claimed keys not to be added to the pool again. Got %d added", added)
+	}
+	if count, _ := TestApp.Store.CountPooledKeys(); count != 28 {
+		t.Errorf("Expected claimed keys not to be counted as available. Got %d keys left", count)
+	}
+}
+
+func TestCreateShortenURLWithTTL(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "ttl": "2h"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	expireTime, err := time.Parse(time.RFC3339, fmt.Sprint(m["expire_time"]))
+	if err != nil {
+		t.Errorf("Failed to parse expire_time %v", m["expire_time"])
+		return
+	}
+	if d := time.Until(expireTime); d < time.Hour || d > 2*time.Hour {
+		t.Errorf("Expected the link to expire in about 2 hours. Got %s", d)
+	}
+}
+
+func TestCreateShortenURLWithInvalidExpiry(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	beyondMax := time.Now().Add(constants.DEFAULT_MAX_LINK_TTL + time.Hour).Format(time.RFC3339)
+
+	for _, payload := range []string{
+		`{"url": "https://www.google.com/", "ttl": "soon"}`,
+		`{"url": "https://www.google.com/", "expire_time": "` + past + `"}`,
+		`{"url": "https://www.google.com/", "expire_time": "` + beyondMax + `"}`,
+		`{"url": "https://www.google.com/", "ttl": "1h", "expire_time": "` + beyondMax + `"}`,
+	} {
+		response := sendRequesttoShortenAPI(payload)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestCreateShortenURLWithPerKeyMaxTTL(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	apiKey, key, err := models.NewAPIKey("short-lived")
+	if err != nil {
+		t.Errorf("Failed to generate API key. ERROR: %s", err.Error())
+		return
+	}
+	apiKey.MaxTTL = time.Hour
+	if err := TestApp.Store.InsertAPIKey(apiKey); err != nil {
+		t.Errorf("Failed to store API key. ERROR: %s", err.Error())
+		return
+	}
+
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url": "https://www.google.com/", "ttl": "2h"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	req, _ = http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url": "https://www.google.com/"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	expireTime, _ := time.Parse(time.RFC3339, fmt.Sprint(m["expire_time"]))
+	if time.Until(expireTime) > time.Hour {
+		t.Errorf("Expected the default expiry to be capped at the key's max ttl. Got %v", m["expire_time"])
+	}
+}
+
+func TestCreateShortenURLWithNoExpiry(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "no_expiry": true}`)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	apiKey, key, err := models.NewAPIKey("permanent")
+	if err != nil {
+		t.Errorf("Failed to generate API key. ERROR: %s", err.Error())
+		return
+	}
+	apiKey.AllowNoExpiry = true
+	if err := TestApp.Store.InsertAPIKey(apiKey); err != nil {
+		t.Errorf("Failed to store API key. ERROR: %s", err.Error())
+		return
+	}
+
+	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url": "https://www.google.com/", "custom_short_key": "forevr", "no_expiry": true}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if expireTime, ok := m["expire_time"]; !ok || expireTime != nil {
+		t.Errorf("Expected expire_time to be null. Got %v", expireTime)
+	}
+
+	req, _ = http.NewRequest("GET", "/forevr", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, response.Code)
+}
+
+func TestRedirectViaShortKey(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if !validateShortenAPIResponse(t, m) {
+		return
+	}
+
+	shortURL := m["short_url"].(string)
+	shortKey := shortURL[len(shortURL)-constants.DEFAULT_SHORT_KEY_LENGTH:]
+
+	req, _ := http.NewRequest("GET", "/"+shortKey, nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusFound, response.Code)
+
+	if response.Result().Header.Get("Location") != "https://www.google.com/" {
+		t.Error("Excepted redirect url https://www.google.com/, found ", response.Result().Header.Get("Location"))
+	}
+}
+
+func TestRedirectType(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "redir1"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ := http.NewRequest("GET", "/redir1", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, response.Code)
+	if cacheControl := response.Header().Get("Cache-Control"); cacheControl != "private, no-store" {
+		t.Errorf("Expected temporary redirects not to be cached. Got '%s'", cacheControl)
+	}
+
+	response = sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "redir2", "redirect_type": 308, "ttl": "1h"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ = http.NewRequest("GET", "/redir2", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusPermanentRedirect, response.Code)
+
+	var maxAge int
+	if _, err := fmt.Sscanf(response.Header().Get("Cache-Control"), "public, max-age=%d", &maxAge); err != nil || maxAge <= 0 || maxAge > 3600 {
+		t.Errorf("Expected permanent redirect to be cached until the link expires. Got '%s'", response.Header().Get("Cache-Control"))
+	}
+
+	response = sendRequestToLinksAPI("PATCH", "redir2", `{"redirect_type": 307}`, testAPIKey)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["redirect_type"] != float64(http.StatusTemporaryRedirect) {
+		t.Errorf("Expected redirect_type to be 307. Got %v", m["redirect_type"])
+	}
+
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusTemporaryRedirect, response.Code)
+
+	response = sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "redirect_type": 303}`)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	response = sendRequestToLinksAPI("PATCH", "redir2", `{"redirect_type": 200}`, testAPIKey)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestRedirectServedFromCache(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if !validateShortenAPIResponse(t, m) {
+		return
+	}
+
+	shortURL := m["short_url"].(string)
+	shortKey := shortURL[len(shortURL)-constants.DEFAULT_SHORT_KEY_LENGTH:]
+
+	// Remove the link from the store only, leaving the cached copy.
+	switch s := TestApp.Store.(type) {
+	case *storage.SQLStore:
+		if _, err := s.DB.Exec("DELETE FROM urls"); err != nil {
+			t.Errorf("Failed to delete short url from the store. ERROR: %s", err.Error())
+			return
+		}
+	case *storage.MemoryStore:
+		s.Truncate("urls")
+	}
+
+	req, _ := http.NewRequest("GET", "/"+shortKey, nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusFound, response.Code)
+
+	if response.Result().Header.Get("Location") != "https://www.google.com/" {
+		t.Error("Excepted redirect url https://www.google.com/, found ", response.Result().Header.Get("Location"))
+	}
+}
+
+func TestRedirectRecordsClick(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+	if err := clearData("clicks"); err != nil {
+		t.Errorf("Could not clear clicks table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "clicks"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ := http.NewRequest("GET", "/clicks", nil)
+	req.Header.Set("Referer", "https://news.example.com/")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148")
+	req.RemoteAddr = testProxyAddr
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	req.Header.Set("X-Country-Code", "de")
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, response.Code)
+
+	TestApp.Clicks.Flush()
+
+	var clicks []models.Click
+	TestApp.Store.IterateClicks("clicks", time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(c models.Click) error {
+		clicks = append(clicks, c)
+		return nil
+	})
+
+	if len(clicks) != 1 {
+		t.Errorf("Expected 1 recorded click. Got %d", len(clicks))
+		return
+	}
+
+	c := clicks[0]
+	if c.Referrer != "https://news.example.com/" || c.Country != "DE" || c.DeviceClass != "mobile" || c.VisitorID == "" {
+		t.Errorf("Recorded click does not match the request. Got %+v", c)
+	}
+}
+
+func TestShortURLStats(t *testing.T) {
+	for _, table := range []string{"urls", "clicks", "click_counts"} {
+		if err := clearData(table); err != nil {
+			t.Errorf("Could not clear %s table. ERROR: %s", table, err.Error())
+			return
+		}
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "stats1"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	for _, ip := range []string{"203.0.113.1", "203.0.113.1", "203.0.113.2"} {
+		req, _ := http.NewRequest("GET", "/stats1", nil)
+		req.RemoteAddr = testProxyAddr
+		req.Header.Set("X-Real-IP", ip)
+		req.Header.Set("X-Country-Code", "IN")
+		executeRequest(req)
+	}
+	TestApp.Clicks.Flush()
+	if err := TestApp.FlushClickCounts(); err != nil {
+		t.Errorf("Could not flush click counts. ERROR: %s", err.Error())
+		return
+	}
+
+	// Older days count towards the totals, but their clicks are not read one
+	// by one for the top values.
+	oldDay := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -20)
+	if err := TestApp.Store.AddClickCounts([]models.ClickCount{{ShortKey: "stats1", Day: oldDay, Clicks: 5, UniqueVisitors: 4}}); err != nil {
+		t.Errorf("Failed to add click counts. ERROR: %s", err.Error())
+		return
+	}
+	if err := TestApp.Store.InsertClicks([]models.Click{{ShortKey: "stats1", ClickedAt: oldDay.Add(time.Hour), Country: "US"}}); err != nil {
+		t.Errorf("Failed to add clicks. ERROR: %s", err.Error())
+		return
+	}
+
+	req, _ := http.NewRequest("GET", "/stats1/stats", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	otherAPIKey, otherKey, err := models.NewAPIKey("stats-other")
+	if err != nil {
+		t.Errorf("Failed to generate API key. ERROR: %s", err.Error())
+		return
+	}
+	if err := TestApp.Store.InsertAPIKey(otherAPIKey); err != nil {
+		t.Errorf("Failed to store API key. ERROR: %s", err.Error())
+		return
+	}
+	req, _ = http.NewRequest("GET", "/stats1/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+otherKey)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	req, _ = http.NewRequest("GET", "/stats1/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var stats struct {
+		TotalClicks    int64 `json:"total_clicks"`
+		UniqueVisitors int64 `json:"unique_visitors"`
+		Daily          []struct {
+			Clicks int64 `json:"clicks"`
+		} `json:"daily"`
+		TopCountries []struct {
+			Value  string `json:"value"`
+			Clicks int64  `json:"clicks"`
+		} `json:"top_countries"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &stats)
+
+	if stats.TotalClicks != 8 || stats.UniqueVisitors != 6 {
+		t.Errorf("Expected 8 clicks from 6 daily unique visitors. Got %d clicks from %d visitors", stats.TotalClicks, stats.UniqueVisitors)
+	}
+	if len(stats.Daily) != 2 || stats.Daily[0].Clicks != 5 || stats.Daily[1].Clicks != 3 {
+		t.Errorf("Expected the old day and today in the daily histogram. Got %+v", stats.Daily)
+	}
+	if len(stats.TopCountries) != 1 || stats.TopCountries[0].Value != "IN" || stats.TopCountries[0].Clicks != 3 {
+		t.Errorf("Expected all clicks from IN in top countries. Got %+v", stats.TopCountries)
+	}
+
+	req, _ = http.NewRequest("GET", "/stats1/stats?from=2024-01-02&to=2024-01-01", nil)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestCountryFromClientIP(t *testing.T) {
+	for _, table := range []string{"urls", "clicks"} {
+		if err := clearData(table); err != nil {
+			t.Errorf("Could not clear %s table. ERROR: %s", table, err.Error())
+			return
+		}
+	}
+
+	database := t.TempDir() + "/countries.csv"
+	if err := os.WriteFile(database, []byte("192.0.2.0,192.0.2.255,de\n2001:db8::,2001:db8::ffff,FR\n"), 0o644); err != nil {
+		t.Errorf("Could not write country database. ERROR: %s", err.Error())
+		return
+	}
+
+	initializeAnalytics := func(countryDatabase string) {
+		TestApp.InitializeAnalytics(
+			config.GetInt("ANALYTICS_BUFFER_SIZE", constants.DEFAULT_ANALYTICS_BUFFER_SIZE),
+			config.GetInt("ANALYTICS_BATCH_SIZE", constants.DEFAULT_ANALYTICS_BATCH_SIZE),
+			config.GetDuration("ANALYTICS_FLUSH_INTERVAL", constants.DEFAULT_ANALYTICS_FLUSH_INTERVAL),
+			config.GetString("COUNTRY_HEADER", constants.DEFAULT_COUNTRY_HEADER),
+			countryDatabase,
+		)
+	}
+	defer initializeAnalytics(os.Getenv("COUNTRY_DATABASE"))
+	initializeAnalytics(database)
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "geo001"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	// The header is only read from requests sent by a trusted proxy.
+	for _, remoteAddr := range []string{"192.0.2.10:1234", "[2001:db8::1]:1234", "198.51.100.1:1234", testProxyAddr} {
+		req, _ := http.NewRequest("GET", "/geo001", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Country-Code", "US")
+		executeRequest(req)
+	}
+	TestApp.Clicks.Flush()
+
+	countries := map[string]int{}
+	err := TestApp.Store.IterateClicks("geo001", time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(c models.Click) error {
+		countries[c.Country]++
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Could not read clicks. ERROR: %s", err.Error())
+	}
+	if countries["DE"] != 1 || countries["FR"] != 1 || countries[""] != 1 || countries["US"] != 1 {
+		t.Errorf("Expected the countries to be resolved from the client IP. Got %v", countries)
+	}
+}
+
+func TestBackgroundIntervalsMustBePositive(t *testing.T) {
+	if err := TestApp.InitializeJanitor(0, time.Hour, 10); err == nil {
+		t.Error("Expected a sweep interval of 0 to be rejected")
+	}
+	if err := TestApp.InitializeClickCounters(-time.Second); err == nil {
+		t.Error("Expected a negative click counts flush interval to be rejected")
+	}
+	if err := TestApp.InitializeAnalytics(10, 10, 0, constants.DEFAULT_COUNTRY_HEADER, ""); err == nil {
+		t.Error("Expected an analytics flush interval of 0 to be rejected")
+	}
+}
+
+func TestClickCountsFlushedToStore(t *testing.T) {
+	for _, table := range []string{"urls", "click_counts"} {
+		if err := clearData(table); err != nil {
+			t.Errorf("Could not clear %s table. ERROR: %s", table, err.Error())
+			return
+		}
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "count1"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	redirect := func(ip string) {
+		req, _ := http.NewRequest("GET", "/count1", nil)
+		req.RemoteAddr = testProxyAddr
+		req.Header.Set("X-Real-IP", ip)
+		executeRequest(req)
+	}
+
+	redirect("198.51.100.1")
+	redirect("198.51.100.1")
+	redirect("198.51.100.2")
+
+	if err := TestApp.FlushClickCounts(); err != nil {
+		t.Errorf("Could not flush click counts. ERROR: %s", err.Error())
+		return
+	}
+
+	redirect("198.51.100.2")
+
+	if err := TestApp.FlushClickCounts(); err != nil {
+		t.Errorf("Could not flush click counts. ERROR: %s", err.Error())
+		return
+	}
+
+	counts, err := TestApp.Store.FetchClickCounts("count1", time.Now().Add(-24*time.Hour), time.Now())
+	if err != nil {
+		t.Errorf("Could not fetch click counts. ERROR: %s", err.Error())
+		return
+	}
+
+	if len(counts) != 1 || counts[0].Clicks != 4 || counts[0].UniqueVisitors != 2 {
+		t.Errorf("Expected 4 clicks from 2 unique visitors for today. Got %+v", counts)
+	}
+}
+
+func TestRedirectRateLimited(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "limit1"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	TestApp.InitializeRateLimiting(ratelimit.Rate{Limit: 1000, Period: time.Minute}, ratelimit.Rate{Limit: 2, Period: time.Minute})
+	defer TestApp.InitializeRateLimiting(ratelimit.Rate{Limit: 1000, Period: time.Minute}, ratelimit.Rate{Limit: 1000, Period: time.Minute})
+
+	redirect := func(ip string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/limit1", nil)
+		req.RemoteAddr = testProxyAddr
+		req.Header.Set("X-Forwarded-For", "198.51.100.9, "+ip+", 10.0.0.1")
+		return executeRequest(req)
 	}
 
-	m.Run()
+	checkResponseCode(t, http.StatusFound, redirect("192.0.2.10").Code)
+	checkResponseCode(t, http.StatusFound, redirect("192.0.2.10").Code)
 
-	if err := database.RollbackMigrations(TestApp.DB, "create_urls_table"); err != nil {
-		log.Fatal(err)
+	response = redirect("192.0.2.10")
+	checkResponseCode(t, http.StatusTooManyRequests, response.Code)
+
+	if response.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on a rate limited response")
+	}
+	if response.Header().Get("RateLimit-Limit") != "2" || response.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected RateLimit headers with limit 2 and nothing remaining. Got limit '%s', remaining '%s'",
+			response.Header().Get("RateLimit-Limit"), response.Header().Get("RateLimit-Remaining"))
+	}
+
+	checkResponseCode(t, http.StatusFound, redirect("192.0.2.11").Code)
+
+	// Proxy headers sent straight to the app do not get a client its own bucket.
+	for i, expected := range []int{http.StatusFound, http.StatusFound, http.StatusTooManyRequests} {
+		req, _ := http.NewRequest("GET", "/limit1", nil)
+		req.RemoteAddr = "192.0.2.50:1234"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		req.Header.Set("X-Real-IP", fmt.Sprintf("198.51.100.%d", i))
+		checkResponseCode(t, expected, executeRequest(req).Code)
 	}
 }
 
-func TestCreateShortenURL(t *testing.T) {
+func TestGetLink(t *testing.T) {
 	if err := clearData("urls"); err != nil {
 		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
 		return
 	}
 
-	var jsonStr = []byte(`{"url":"https://www.google.com/"}`)
-	req, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(jsonStr))
-	req.Header.Set("Content-Type", "application/json")
-
-	response := executeRequest(req)
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "crud01"}`)
 	checkResponseCode(t, http.StatusCreated, response.Code)
 
+	response = sendRequestToLinksAPI("GET", "crud01", "", testAPIKey)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
 	var m map[string]interface{}
 	json.Unmarshal(response.Body.Bytes(), &m)
 
-	if !validateShortenAPIResponse(t, m) {
+	if m["short_key"] != "crud01" || m["original_url"] != "https://www.google.com/" || m["disabled"] != false {
+		t.Errorf("Unexpected link metadata %v", m)
+	}
+
+	apiKey, key, err := models.NewAPIKey("other")
+	if err != nil {
+		t.Errorf("Failed to generate API key. ERROR: %s", err.Error())
+		return
+	}
+	if err := TestApp.Store.InsertAPIKey(apiKey); err != nil {
+		t.Errorf("Failed to store API key. ERROR: %s", err.Error())
 		return
 	}
+
+	response = sendRequestToLinksAPI("GET", "crud01", "", key)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	response = sendRequestToLinksAPI("GET", "crud01", "", "")
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
 }
 
-func TestCreateShortenURLWithEmptyPayload(t *testing.T) {
+func TestUpdateLink(t *testing.T) {
 	if err := clearData("urls"); err != nil {
 		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
 		return
 	}
 
-	response := sendRequesttoShortenAPI(`{}`)
-	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "crud02"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
 
-	var m map[string]string
+	req, _ := http.NewRequest("GET", "/crud02", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, response.Code)
+
+	response = sendRequestToLinksAPI("PATCH", "crud02", `{"url": "https://www.example.com/", "ttl": "1h"}`, testAPIKey)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
 	json.Unmarshal(response.Body.Bytes(), &m)
-	if m["error"] != "URL not given" {
-		t.Errorf("Expected the 'error' key of the response to be set to 'URL not given'. Got '%s'", m["error"])
+
+	expireTime, _ := time.Parse(time.RFC3339, fmt.Sprint(m["expire_time"]))
+	if d := time.Until(expireTime); d <= 0 || d > time.Hour {
+		t.Errorf("Expected the link to expire within the hour. Got %v", m["expire_time"])
+	}
+
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, response.Code)
+	if location := response.Header().Get("Location"); location != "https://www.example.com/" {
+		t.Errorf("Expected the redirect to use the updated URL. Got '%s'", location)
 	}
+
+	response = sendRequestToLinksAPI("PATCH", "crud02", `{"disabled": true}`, testAPIKey)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	response = sendRequestToLinksAPI("PATCH", "crud02", `{"disabled": false}`, testAPIKey)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, response.Code)
+
+	response = sendRequestToLinksAPI("PATCH", "crud02", `{"url": "not a url"}`, testAPIKey)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
 }
 
-func TestCreateShortenURLWithInvalidURL(t *testing.T) {
+func TestDeleteLink(t *testing.T) {
 	if err := clearData("urls"); err != nil {
 		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
 		return
 	}
 
-	response := sendRequesttoShortenAPI(`{"url":"google.com"}`)
-	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "crud03"}`)
+	checkResponseCode(t, http.StatusCreated, response.Code)
 
-	var m map[string]string
-	json.Unmarshal(response.Body.Bytes(), &m)
-	if m["error"] != "Invalid URL given" {
-		t.Errorf("Expected the 'error' key of the response to be set to 'Invalid URL given'. Got '%s'", m["error"])
-	}
+	response = sendRequestToLinksAPI("DELETE", "crud03", "", testAPIKey)
+	checkResponseCode(t, http.StatusNoContent, response.Code)
+
+	req, _ := http.NewRequest("GET", "/crud03", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusGone, response.Code)
+
+	response = sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "crud03"}`)
+	checkResponseCode(t, http.StatusNotAcceptable, response.Code)
+
+	response = sendRequestToLinksAPI("DELETE", "crud04", "", testAPIKey)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
 }
 
-func TestCreateShortenURLWithCustomURL(t *testing.T) {
+func TestListLinks(t *testing.T) {
 	if err := clearData("urls"); err != nil {
 		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
 		return
 	}
 
-	response := sendRequesttoShortenAPI(`{"url":"https://www.google.com/", "custom_short_key": "654321"}`)
-	checkResponseCode(t, http.StatusCreated, response.Code)
+	for _, payload := range []string{
+		`{"url": "https://www.google.com/", "custom_short_key": "list01", "tags": ["Search"]}`,
+		`{"url": "https://docs.example.com/a", "custom_short_key": "list02", "tags": ["docs", "search"]}`,
+		`{"url": "https://example.com/b", "custom_short_key": "list03"}`,
+	} {
+		response := sendRequesttoShortenAPI(payload)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	}
 
-	var m map[string]interface{}
-	json.Unmarshal(response.Body.Bytes(), &m)
+	expireTime := time.Now().Add(-time.Hour)
+	apiKey, _ := TestApp.Store.FetchAPIKeyByHash(models.HashAPIKey(testAPIKey))
+	expired := models.ShortenURL{OriginalURL: "https://example.com/c", ShortKey: "list04", ExpireTime: &expireTime, APIKeyID: apiKey.ID}
+	if err := TestApp.Store.InsertShortURL(&expired); err != nil {
+		t.Errorf("Failed to add original url to DB. ERROR: %s", err.Error())
+		return
+	}
+	other := models.ShortenURL{OriginalURL: "https://example.com/d", ShortKey: "list05"}
+	if err := TestApp.Store.InsertShortURL(&other); err != nil {
+		t.Errorf("Failed to add original url to DB. ERROR: %s", err.Error())
+		return
+	}
 
-	if !validateShortenAPIResponse(t, m) {
+	if err := TestApp.Store.AddClickCounts([]models.ClickCount{{ShortKey: "list01", Day: time.Now().UTC().Truncate(24 * time.Hour), Clicks: 5}, {ShortKey: "list03", Day: time.Now().UTC().Truncate(24 * time.Hour), Clicks: 2}}); err != nil {
+		t.Errorf("Failed to add click counts. ERROR: %s", err.Error())
 		return
 	}
 
-	shortURL := m["short_url"].(string)
-	shortKey := shortURL[len(shortURL)-constants.SHORT_KEY_LENGTH:]
+	var keys []string
+	tags := map[string]string{}
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		m := listLinks(t, "limit=3&cursor="+cursor)
+		for _, l := range m.Links {
+			keys = append(keys, l.ShortKey)
+			tags[l.ShortKey] = strings.Join(l.Tags, ",")
+		}
+		if cursor = m.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if strings.Join(keys, ",") != "list04,list03,list02,list01" {
+		t.Errorf("Expected all links owned by the key, newest first. Got %v", keys)
+	}
+	if tags["list01"] != "search" || tags["list02"] != "docs,search" || tags["list03"] != "" {
+		t.Errorf("Expected each link listed with its own tags. Got %v", tags)
+	}
 
-	if shortKey != "654321" {
-		t.Errorf("Expected short key to be (654321), got %s", shortKey)
+	for query, expected := range map[string]string{
+		"tag=search":                    "list02,list01",
+		"domain=example.com":            "list04,list03,list02",
+		"domain=docs.example.com":       "list02",
+		"status=expired":                "list04",
+		"status=active&sort=created_at": "list01,list02,list03",
+		"sort=-click_count&limit=2":     "list01,list03",
+	} {
+		var keys []string
+		for _, l := range listLinks(t, query).Links {
+			keys = append(keys, l.ShortKey)
+		}
+		if strings.Join(keys, ",") != expected {
+			t.Errorf("Expected %s for %q. Got %v", expected, query, keys)
+		}
+	}
+
+	for _, query := range []string{"status=deleted", "sort=name", "limit=0", "cursor=invalid", "created_from=yesterday"} {
+		req, _ := http.NewRequest("GET", "/api/links?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+testAPIKey)
+		response := executeRequest(req)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
 	}
 }
 
-func TestCreateShortenURLWithInvalidCustomURL_SpecialCharValidation(t *testing.T) {
+func TestImportLinksCSV(t *testing.T) {
 	if err := clearData("urls"); err != nil {
 		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
 		return
 	}
 
-	response := sendRequesttoShortenAPI(`{"url":"https://www.google.com/", "custom_short_key": "65#321"}`)
-	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	expireTime := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	payload := "url,custom_short_key,expire_time\n" +
+		"https://www.google.com/,csv001," + expireTime + "\n" +
+		"not a url,,\n" +
+		"https://www.example.com/,,\n" +
+		"https://www.example.com/,csv001,\n" +
+		"https://www.example.com/,,someday\n"
+
+	req, _ := http.NewRequest("POST", "/api/links/import", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
 
-	var m map[string]string
+	var m app.ImportResult
 	json.Unmarshal(response.Body.Bytes(), &m)
-	if m["error"] != "Invalid custom short key" {
-		t.Errorf("Expected the 'error' key of the response to be set to 'Invalid custom short key'. Got '%s'", m["error"])
-	}
-}
 
-func TestCreateShortenURLWithInvalidCustomURL_LengthValidation(t *testing.T) {
-	if err := clearData("urls"); err != nil {
-		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
-		return
+	if m.Created != 2 || m.Failed != 3 {
+		t.Errorf("Expected 2 created and 3 failed rows. Got %d and %d", m.Created, m.Failed)
 	}
 
-	response := sendRequesttoShortenAPI(`{"url":"https://www.google.com/", "custom_short_key": "1234567"}`)
-	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	var lines []int
+	for _, e := range m.Errors {
+		lines = append(lines, e.Line)
+	}
+	if fmt.Sprint(lines) != "[3 5 6]" {
+		t.Errorf("Expected errors on lines 3, 5 and 6. Got %+v", m.Errors)
+	}
 
-	var m map[string]string
-	json.Unmarshal(response.Body.Bytes(), &m)
-	if m["error"] != "Invalid custom short key" {
-		t.Errorf("Expected the 'error' key of the response to be set to 'Invalid custom short key'. Got '%s'", m["error"])
+	if fetchOriginalURL("csv001") != "https://www.google.com/" {
+		t.Error("Expected the imported short key to be stored")
 	}
 }
 
-func TestRedirectViaShortKey(t *testing.T) {
+func TestExportLinks(t *testing.T) {
 	if err := clearData("urls"); err != nil {
 		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
 		return
 	}
 
-	response := sendRequesttoShortenAPI(`{"url": "https://www.google.com/"}`)
-	checkResponseCode(t, http.StatusCreated, response.Code)
+	for _, payload := range []string{
+		`{"url": "https://www.google.com/", "custom_short_key": "exprt1", "tags": ["a", "b"]}`,
+		`{"url": "https://www.example.com/", "custom_short_key": "exprt2"}`,
+	} {
+		response := sendRequesttoShortenAPI(payload)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	}
 
-	var m map[string]interface{}
-	json.Unmarshal(response.Body.Bytes(), &m)
-	if !validateShortenAPIResponse(t, m) {
+	req, _ := http.NewRequest("GET", "/api/links/export", nil)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	records, err := csv.NewReader(response.Body).ReadAll()
+	if err != nil || len(records) != 3 {
+		t.Errorf("Expected a header and 2 CSV rows. Got %v (%v)", records, err)
 		return
 	}
+	if records[1][0] != "exprt1" || records[1][1] != "https://www.google.com/" || records[1][3] != "a;b" || records[1][4] != "0" {
+		t.Errorf("Unexpected CSV row %v", records[1])
+	}
 
-	shortURL := m["short_url"].(string)
-	shortKey := shortURL[len(shortURL)-constants.SHORT_KEY_LENGTH:]
-
-	req, _ := http.NewRequest("GET", "/"+shortKey, nil)
+	req, _ = http.NewRequest("GET", "/api/links/export?format=ndjson", nil)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
 	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
 
-	checkResponseCode(t, http.StatusMovedPermanently, response.Code)
-
-	if response.Result().Header.Get("Location") != "https://www.google.com/" {
-		t.Error("Excepted redirect url https://www.google.com/, found ", response.Result().Header.Get("Location"))
+	lines := strings.Split(strings.TrimSpace(response.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Errorf("Expected 2 NDJSON lines. Got %d", len(lines))
+		return
+	}
+	var link app.Link
+	json.Unmarshal([]byte(lines[1]), &link)
+	if link.ShortKey != "exprt2" {
+		t.Errorf("Expected the second line to hold exprt2. Got %+v", link)
 	}
 }
 
@@ -223,17 +1704,103 @@ func TestGetExpiredShortKey(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/"+shortKey, nil)
 	response := executeRequest(req)
 
-	checkResponseCode(t, http.StatusNotFound, response.Code)
+	checkResponseCode(t, http.StatusGone, response.Code)
 
 	var m map[string]string
 	json.Unmarshal(response.Body.Bytes(), &m)
-	if m["error"] != "Short Key not found" {
-		t.Errorf("Expected the 'error' key of the response to be set to 'Short Key not found'. Got '%s'", m["error"])
+	if m["error"] != "Short Key expired" {
+		t.Errorf("Expected the 'error' key of the response to be set to 'Short Key expired'. Got '%s'", m["error"])
+	}
+
+	if _, _, err := TestApp.SweepExpiredURLs(); err != nil {
+		t.Errorf("Failed to sweep expired short urls. ERROR: %s", err.Error())
+		return
+	}
+
+	if fetchOriginalURL(shortKey) == "" {
+		t.Error("Expected the expired short key to be archived, but it was deleted")
+	}
+
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusGone, response.Code)
+
+	response = sendRequesttoShortenAPI(`{"url": "https://www.google.com/", "custom_short_key": "` + shortKey + `"}`)
+	checkResponseCode(t, http.StatusNotAcceptable, response.Code)
+}
+
+func TestExpirySweeperMetrics(t *testing.T) {
+	if err := clearData("urls"); err != nil {
+		t.Errorf("Could not clear urls table. ERROR: %s", err.Error())
+		return
+	}
+	if err := clearData("clicks"); err != nil {
+		t.Errorf("Could not clear clicks table. ERROR: %s", err.Error())
+		return
+	}
+
+	for i, expireTime := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(-time.Minute), time.Now().Add(time.Hour)} {
+		expireTime := expireTime
+		u := models.ShortenURL{OriginalURL: "https://www.google.com/", ShortKey: fmt.Sprintf("sweep%d", i), ExpireTime: &expireTime}
+		if err := TestApp.Store.InsertShortURL(&u); err != nil {
+			t.Errorf("Failed to add original url to DB. ERROR: %s", err.Error())
+			return
+		}
+	}
+
+	expireTime := time.Now().AddDate(0, 0, -60)
+	archivedAt := time.Now().Add(-constants.DEFAULT_ARCHIVE_QUARANTINE - time.Hour)
+	quarantined := models.ShortenURL{OriginalURL: "https://www.google.com/", ShortKey: "sweep3", ExpireTime: &expireTime, ArchivedAt: &archivedAt}
+	if err := TestApp.Store.InsertShortURL(&quarantined); err != nil {
+		t.Errorf("Failed to add original url to DB. ERROR: %s", err.Error())
+		return
+	}
+	if err := TestApp.Store.InsertClicks([]models.Click{{ShortKey: "sweep3", ClickedAt: expireTime}}); err != nil {
+		t.Errorf("Failed to add click to DB. ERROR: %s", err.Error())
+		return
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("METRICS_TOKEN"))
+	response := executeRequest(req)
+	var before map[string]map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &before)
+
+	archived, purged, err := TestApp.SweepExpiredURLs()
+	if err != nil {
+		t.Errorf("Failed to sweep expired short urls. ERROR: %s", err.Error())
+		return
 	}
+	if archived != 2 || purged != 1 {
+		t.Errorf("Expected 2 short urls to be archived and 1 purged. Got %d and %d", archived, purged)
+	}
+
+	if fetchOriginalURL("sweep2") == "" {
+		t.Error("Expected the unexpired short key to be kept")
+	}
+	if fetchOriginalURL("sweep3") != "" {
+		t.Error("Expected the short key past its quarantine to be deleted, but found it")
+	}
+
+	clicks := 0
+	TestApp.Store.IterateClicks("sweep3", time.Time{}, time.Now(), func(c models.Click) error {
+		clicks++
+		return nil
+	})
+	if clicks != 0 {
+		t.Errorf("Expected the clicks of the purged short key to be deleted. Found %d", clicks)
+	}
+
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
 
-	originalURL := fetchOriginalURL(shortKey)
-	if originalURL != "" {
-		t.Error("Expected the expired short key to be deleted from the DB, but found it")
+	var after map[string]map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &after)
+
+	if after["janitor"]["rows_archived"].(float64)-before["janitor"]["rows_archived"].(float64) != 2 {
+		t.Errorf("Expected rows_archived to grow by 2. Got %v before and %v after", before["janitor"]["rows_archived"], after["janitor"]["rows_archived"])
+	}
+	if after["janitor"]["rows_purged"].(float64)-before["janitor"]["rows_purged"].(float64) != 1 {
+		t.Errorf("Expected rows_purged to grow by 1. Got %v before and %v after", before["janitor"]["rows_purged"], after["janitor"]["rows_purged"])
 	}
 }
 
@@ -243,7 +1810,7 @@ func TestInvalidShortKey_LengthValidation(t *testing.T) {
 		return
 	}
 
-	req, _ := http.NewRequest("GET", "/1234567", nil)
+	req, _ := http.NewRequest("GET", "/launch2026launch2026x", nil)
 	response := executeRequest(req)
 
 	checkResponseCode(t, http.StatusBadRequest, response.Code)
@@ -261,7 +1828,7 @@ func TestInvalidShortKey_SpecialCharValidation(t *testing.T) {
 		return
 	}
 
-	req, _ := http.NewRequest("GET", "/123#56", nil)
+	req, _ := http.NewRequest("GET", "/123$56", nil)
 	response := executeRequest(req)
 
 	checkResponseCode(t, http.StatusBadRequest, response.Code)
@@ -302,31 +1869,235 @@ func TestDuplicateOriginalURL(t *testing.T) {
 	}
 }
 
-func clearData(tableName string) error {
-	if _, err := TestApp.DB.Exec("DELETE FROM " + tableName); err != nil {
-		return err
+// TestStores runs the same checks against the in-memory store and a fresh
+// SQLite database, so both stay interchangeable behind models.Store.
+func TestStores(t *testing.T) {
+	sqlStore, err := storage.NewSQLStore("sqlite", "", "", "", filepath.Join(t.TempDir(), "stores.db"))
+	if err != nil {
+		t.Fatalf("Could not open the SQLite store. ERROR: %s", err.Error())
+	}
+	defer sqlStore.Close()
+	if err := sqlStore.Migrate(); err != nil {
+		t.Fatalf("Could not migrate the SQLite store. ERROR: %s", err.Error())
+	}
+
+	for name, store := range map[string]models.Store{"memory": storage.NewMemoryStore(), "sqlite": sqlStore} {
+		t.Run(name, func(t *testing.T) {
+			checkStoreURLs(t, store)
+			checkStoreKeys(t, store)
+			checkStoreClicks(t, store)
+			checkStoreAPIKeys(t, store)
+		})
+	}
+}
+
+func checkStoreURLs(t *testing.T, store models.Store) {
+	past := time.Now().UTC().Add(-time.Hour)
+	urls := []*models.ShortenURL{
+		{ShortKey: "store1", OriginalURL: "https://example.com/a", NormalizedURL: "https://example.com/a", APIKeyID: 1, RedirectType: 302},
+		{ShortKey: "store2", OriginalURL: "https://example.com/a", NormalizedURL: "https://example.com/a", APIKeyID: 2, RedirectType: 302},
+		{ShortKey: "store3", OriginalURL: "https://example.com/b", NormalizedURL: "https://example.com/b", APIKeyID: 1, RedirectType: 302, ExpireTime: &past},
+	}
+	for _, u := range urls {
+		if err := store.InsertShortURL(u); err != nil {
+			t.Fatalf("Could not insert %s. ERROR: %s", u.ShortKey, err.Error())
+		}
+	}
+
+	if err := store.InsertShortURL(&models.ShortenURL{ShortKey: "store1", OriginalURL: "https://example.com/c"}); !errors.Is(err, models.ErrShortKeyExists) {
+		t.Errorf("Expected a taken key to return ErrShortKeyExists. Got %v", err)
+	}
+	if exists, err := store.ShortKeyExists("store1"); err != nil || !exists {
+		t.Errorf("Expected store1 to exist. Got %v, %v", exists, err)
+	}
+	if count, err := store.CountShortKeys(6); err != nil || count != 3 {
+		t.Errorf("Expected 3 keys of length 6. Got %d, %v", count, err)
+	}
+
+	fetched := models.ShortenURL{ShortKey: "store1"}
+	if err := store.FetchShortURL(&fetched); err != nil || fetched.OriginalURL != "https://example.com/a" {
+		t.Errorf("Expected to fetch store1. Got %+v, %v", fetched, err)
+	}
+	if err := store.FetchShortURL(&models.ShortenURL{ShortKey: "nokey1"}); !errors.Is(err, models.ErrShortURLNotFound) {
+		t.Errorf("Expected ErrShortURLNotFound for an unknown key. Got %v", err)
+	}
+
+	active := models.ShortenURL{NormalizedURL: "https://example.com/a", APIKeyID: 2}
+	if err := store.FetchActiveShortURLByURL(&active); err != nil || active.ShortKey != "store2" {
+		t.Errorf("Expected the link of the same API key. Got %q, %v", active.ShortKey, err)
+	}
+	if err := store.FetchActiveShortURLByURL(&models.ShortenURL{NormalizedURL: "https://example.com/b", APIKeyID: 1}); !errors.Is(err, models.ErrShortURLNotFound) {
+		t.Errorf("Expected expired links not to be active. Got %v", err)
+	}
+
+	fetched.Disabled = true
+	if err := store.UpdateShortURL(&fetched); err != nil {
+		t.Errorf("Could not update store1. ERROR: %s", err.Error())
+	}
+	if err := store.FetchActiveShortURLByURL(&models.ShortenURL{NormalizedURL: "https://example.com/a", APIKeyID: 1}); !errors.Is(err, models.ErrShortURLNotFound) {
+		t.Errorf("Expected disabled links not to be active. Got %v", err)
 	}
-	if _, err := TestApp.DB.Exec("ALTER TABLE " + tableName + " AUTO_INCREMENT = 1"); err != nil {
-		return err
+
+	if keys, err := store.ArchiveExpiredShortURLs(time.Now(), 10); err != nil || len(keys) != 1 || keys[0] != "store3" {
+		t.Errorf("Expected store3 to be archived. Got %v, %v", keys, err)
+	}
+
+	batch, err := store.BeginBatch()
+	if err != nil {
+		t.Fatalf("Could not begin a batch. ERROR: %s", err.Error())
+	}
+	if err := batch.InsertShortURL(&models.ShortenURL{ShortKey: "batch9", OriginalURL: "https://example.com/d"}); err != nil {
+		t.Errorf("Could not insert in the batch. ERROR: %s", err.Error())
+	}
+	if err := batch.InsertShortURL(&models.ShortenURL{ShortKey: "store2", OriginalURL: "https://example.com/d"}); !errors.Is(err, models.ErrShortKeyExists) {
+		t.Errorf("Expected a taken key in the batch to return ErrShortKeyExists. Got %v", err)
+	}
+	if err := batch.Rollback(); err != nil {
+		t.Errorf("Could not roll back the batch. ERROR: %s", err.Error())
+	}
+	if exists, _ := store.ShortKeyExists("batch9"); exists {
+		t.Error("Expected a rolled back batch to insert nothing")
+	}
+}
+
+func checkStoreKeys(t *testing.T, store models.Store) {
+	first, err := store.ReserveKeySequence(10)
+	if err != nil {
+		t.Fatalf("Could not reserve a key sequence. ERROR: %s", err.Error())
+	}
+	if second, err := store.ReserveKeySequence(10); err != nil || second != first+10 {
+		t.Errorf("Expected the next block to start at %d. Got %d, %v", first+10, second, err)
+	}
+
+	if added, err := store.AddPooledKeys([]string{"pool01", "pool02", "pool02", "store2"}); err != nil || added != 2 {
+		t.Errorf("Expected 2 keys to be pooled. Got %d, %v", added, err)
+	}
+	keys, err := store.TakePooledKeys(1)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("Expected to take 1 pooled key. Got %v, %v", keys, err)
+	}
+	if count, err := store.CountPooledKeys(); err != nil || count != 1 {
+		t.Errorf("Expected 1 unclaimed key. Got %d, %v", count, err)
+	}
+	if added, err := store.AddPooledKeys(keys); err != nil || added != 0 {
+		t.Errorf("Expected a claimed key not to be pooled again. Got %d, %v", added, err)
+	}
+	if more, err := store.TakePooledKeys(5); err != nil || len(more) != 1 || more[0] == keys[0] {
+		t.Errorf("Expected only the unclaimed key to be taken. Got %v, %v", more, err)
+	}
+}
+
+func checkStoreClicks(t *testing.T, store models.Store) {
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	clicks := []models.Click{
+		{ShortKey: "store2", ClickedAt: day.Add(time.Hour), Country: "IN"},
+		{ShortKey: "store2", ClickedAt: day.Add(2 * time.Hour), Country: "US"},
+		{ShortKey: "store2", ClickedAt: day.Add(-time.Hour), Country: "US"},
+	}
+	if err := store.InsertClicks(clicks); err != nil {
+		t.Fatalf("Could not insert clicks. ERROR: %s", err.Error())
+	}
+
+	var countries []string
+	err := store.IterateClicks("store2", day, day.Add(24*time.Hour), func(c models.Click) error {
+		countries = append(countries, c.Country)
+		return nil
+	})
+	if err != nil || len(countries) != 2 || countries[0] != "IN" {
+		t.Errorf("Expected the 2 clicks of today in order. Got %v, %v", countries, err)
+	}
+
+	counts := []models.ClickCount{{ShortKey: "store2", Day: day, Clicks: 2, UniqueVisitors: 1}}
+	for i := 0; i < 2; i++ {
+		if err := store.AddClickCounts(counts); err != nil {
+			t.Fatalf("Could not add click counts. ERROR: %s", err.Error())
+		}
+	}
+	if err := store.AddClickCounts([]models.ClickCount{{ShortKey: "store2", Day: day.AddDate(0, 0, -1), Clicks: 1}}); err != nil {
+		t.Fatalf("Could not add click counts. ERROR: %s", err.Error())
+	}
+
+	fetched, err := store.FetchClickCounts("store2", day, day.Add(24*time.Hour))
+	if err != nil || len(fetched) != 1 || fetched[0].Clicks != 4 || fetched[0].UniqueVisitors != 1 || !fetched[0].Day.Equal(day) {
+		t.Errorf("Expected today's counts to add up to 4 clicks and keep 1 unique visitor. Got %+v, %v", fetched, err)
+	}
+	if fetched, err := store.FetchClickCounts("store2", day.Add(-time.Hour), day.Add(time.Hour)); err != nil || len(fetched) != 1 {
+		t.Errorf("Expected only the day starting within the range. Got %+v, %v", fetched, err)
+	}
+}
+
+func checkStoreAPIKeys(t *testing.T, store models.Store) {
+	key := &models.APIKey{Name: "store", Prefix: "sk_store", KeyHash: "store-hash", MaxTTL: time.Hour}
+	if err := store.InsertAPIKey(key); err != nil || key.ID == 0 {
+		t.Fatalf("Could not insert the API key. Got ID %d, %v", key.ID, err)
+	}
+
+	fetched, err := store.FetchAPIKeyByHash("store-hash")
+	if err != nil || fetched.ID != key.ID || fetched.MaxTTL != time.Hour || fetched.RevokedAt != nil {
+		t.Errorf("Expected to fetch the API key. Got %+v, %v", fetched, err)
+	}
+	if _, err := store.FetchAPIKeyByHash("missing"); !errors.Is(err, models.ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound for an unknown hash. Got %v", err)
+	}
+
+	if err := store.RevokeAPIKey(key.ID); err != nil {
+		t.Errorf("Could not revoke the API key. ERROR: %s", err.Error())
+	}
+	if err := store.RevokeAPIKey(key.ID); !errors.Is(err, models.ErrAPIKeyNotFound) {
+		t.Errorf("Expected revoking twice to return ErrAPIKeyNotFound. Got %v", err)
+	}
+	if keys, err := store.ListAPIKeys(); err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("Expected the revoked API key to be listed. Got %+v, %v", keys, err)
+	}
+}
+
+// restoreLinkSettings reapplies the configured link settings, such as the key
+// generator, after a test changed them.
+func restoreLinkSettings(t *testing.T) {
+	if err := initializeLinks(TestApp); err != nil {
+		t.Errorf("Could not restore the link settings. ERROR: %s", err.Error())
+	}
+}
+
+func clearData(tableName string) error {
+	switch s := TestApp.Store.(type) {
+	case *storage.SQLStore:
+		if _, err := s.DB.Exec("DELETE FROM " + tableName); err != nil {
+			return err
+		}
+	case *storage.MemoryStore:
+		s.Truncate(tableName)
 	}
-	if _, err := TestApp.Redis.FlushAllAsync(context.Background()).Result(); err != nil {
-		return err
+	switch c := TestApp.Cache.(type) {
+	case *cache.RedisCache:
+		if _, err := c.Client.FlushAllAsync(context.Background()).Result(); err != nil {
+			return err
+		}
+	case *cache.LRUCache:
+		c.Purge()
 	}
 	return nil
 }
 
 func addShortKey(originalURL string, expireTime time.Time) (string, error) {
-	shortKey := "123456"
-	_, err := TestApp.DB.Exec("INSERT INTO urls(original_url, short_key, expire_time) VALUES(?, ?, ?)", originalURL, shortKey, expireTime)
-	return shortKey, err
+	u := models.ShortenURL{
+		OriginalURL: originalURL,
+		ShortKey:    "123456",
+		ExpireTime:  &expireTime,
+	}
+	err := TestApp.Store.InsertShortURL(&u)
+	return u.ShortKey, err
 }
 
 func fetchOriginalURL(shortKey string) string {
-	var originalURL string
-	TestApp.DB.QueryRow("SELECT original_url FROM urls WHERE short_key = ? LIMIT 1", shortKey).Scan(&originalURL)
-	return originalURL
+	u := models.ShortenURL{ShortKey: shortKey}
+	u.FetchShortURLData(TestApp.Store)
+	return u.OriginalURL
 }
 
+// testProxyAddr is within TRUSTED_PROXIES in .testing.env.
+const testProxyAddr = "10.0.0.2:1234"
+
 func executeRequest(req *http.Request) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	TestApp.Router.ServeHTTP(rr, req)
@@ -363,7 +2134,7 @@ func validateShortenAPIResponse(t *testing.T, m map[string]interface{}) bool {
 		return false
 	}
 
-	regexPattern := fmt.Sprintf(`^http://%s:%s/[A-Z a-z 0-9]{%d}$`, os.Getenv("APP_URL"), os.Getenv("PORT"), constants.SHORT_KEY_LENGTH)
+	regexPattern := fmt.Sprintf(`^http://%s:%s/[A-Z a-z 0-9]{%d}$`, os.Getenv("APP_URL"), os.Getenv("PORT"), constants.DEFAULT_SHORT_KEY_LENGTH)
 	if ok, _ := regexp.MatchString(regexPattern, m["short_url"].(string)); !ok {
 		t.Errorf("Expected short_url format to be 'http://%s:%s/xxxxxx'. Got '%v'", os.Getenv("APP_URL"), os.Getenv("PORT"), m["short_url"])
 		return false
@@ -376,6 +2147,38 @@ func sendRequesttoShortenAPI(paylaod string) *httptest.ResponseRecorder {
 	var jsonStr1 = []byte(paylaod)
 	req1, _ := http.NewRequest("POST", "/shorten", bytes.NewBuffer(jsonStr1))
 	req1.Header.Set("Content-Type", "application/json")
+	req1.Header.Set("Authorization", "Bearer "+testAPIKey)
 	response := executeRequest(req1)
 	return response
 }
+
+func sendRequestToLinksAPI(method, key, payload, apiKey string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/api/links/"+key, bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	return executeRequest(req)
+}
+
+func listLinks(t *testing.T, query string) app.LinkList {
+	req, _ := http.NewRequest("GET", "/api/links?"+query, nil)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m app.LinkList
+	json.Unmarshal(response.Body.Bytes(), &m)
+	return m
+}
+
+func sendRequestToBatchAPI(t *testing.T, payload string) app.BatchResponse {
+	req, _ := http.NewRequest("POST", "/shorten/batch", bytes.NewBufferString(payload))
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m app.BatchResponse
+	json.Unmarshal(response.Body.Bytes(), &m)
+	return m
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+type APIKey struct {
+	ID            int64         `json:"id"`
+	Name          string        `json:"name"`
+	Prefix        string        `json:"prefix"`
+	KeyHash       string        `json:"-"`
+	MaxTTL        time.Duration `json:"max_ttl"`
+	AllowNoExpiry bool          `json:"allow_no_expiry"`
+	CreatedAt     time.Time     `json:"created_at"`
+	RevokedAt     *time.Time    `json:"revoked_at,omitempty"`
+}
+
+func NewAPIKey(name string) (*APIKey, string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	key := "us_" + hex.EncodeToString(secret)
+
+	return &APIKey{
+		Name:      name,
+		Prefix:    key[:11],
+		KeyHash:   HashAPIKey(key),
+		CreatedAt: time.Now().UTC(),
+	}, key, nil
+}
+
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func (k *APIKey) Active() bool {
+	return k.RevokedAt == nil
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type ClickCount struct {
+	ShortKey       string    `json:"short_key"`
+	Day            time.Time `json:"day"`
+	Clicks         int64     `json:"clicks"`
+	UniqueVisitors int64     `json:"unique_visitors"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Click struct {
+	ShortKey    string    `json:"short_key"`
+	ClickedAt   time.Time `json:"clicked_at"`
+	Referrer    string    `json:"referrer"`
+	UserAgent   string    `json:"user_agent"`
+	Country     string    `json:"country"`
+	DeviceClass string    `json:"device_class"`
+	VisitorID   string    `json:"-"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Conero007/url-shortener/constants"
+)
+
+const (
+	LinkStatusActive  = "active"
+	LinkStatusExpired = "expired"
+
+	LinkSortCreatedAt  = "created_at"
+	LinkSortClickCount = "click_count"
+)
+
+var tagPattern = regexp.MustCompile(fmt.Sprintf(`^[a-z0-9_-]{1,%d}$`, constants.MAX_TAG_LENGTH))
+
+// LinkCursor identifies the last link of a page; the next page starts right
+// after it in the requested sort order.
+type LinkCursor struct {
+	CreatedAt  time.Time `json:"c,omitempty"`
+	ClickCount int64     `json:"n,omitempty"`
+	ID         int64     `json:"i"`
+}
+
+type LinkFilter struct {
+	APIKeyID    int64
+	Domain      string
+	Tag         string
+	Status      string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	SortBy      string
+	Ascending   bool
+	After       *LinkCursor
+	Limit       int
+}
+
+func (u *ShortenURL) Cursor() LinkCursor {
+	return LinkCursor{CreatedAt: u.CreatedAt, ClickCount: u.ClickCount, ID: u.ID}
+}
+
+// NormalizeTags lowercases and deduplicates tags, rejecting invalid ones.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("Invalid tag %q", tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	if len(normalized) > constants.MAX_TAGS_PER_LINK {
+		return nil, fmt.Errorf("At most %d tags can be given", constants.MAX_TAGS_PER_LINK)
+	}
+	return normalized, nil
+}
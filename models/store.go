@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrShortKeyExists   = errors.New("short key already exists")
+	ErrShortURLNotFound = errors.New("short url not found")
+	ErrAPIKeyNotFound   = errors.New("api key not found")
+	ErrNoShortKeyLeft   = errors.New("no short key could be allocated")
+)
+
+type URLInserter interface {
+	InsertShortURL(u *ShortenURL) error
+}
+
+// URLBatch inserts short URLs in a single transaction. A failed insert only
+// discards that URL, the others are kept until Commit or Rollback. Nothing
+// else may use the store while a batch is open: SQLite has one connection,
+// which the batch holds until then.
+type URLBatch interface {
+	URLInserter
+	Commit() error
+	Rollback() error
+}
+
+type URLStore interface {
+	URLInserter
+	BeginBatch() (URLBatch, error)
+	FetchShortURL(u *ShortenURL) error
+	FetchActiveShortURLByURL(u *ShortenURL) error
+	UpdateShortURL(u *ShortenURL) error
+	ShortKeyExists(shortKey string) (bool, error)
+	ShortKeyCounter
+	ListShortURLs(filter LinkFilter) ([]ShortenURL, error)
+	ArchiveExpiredShortURLs(before time.Time, limit int) ([]string, error)
+	PurgeArchivedShortURLs(before time.Time, limit int) ([]string, error)
+	Close() error
+}
+
+type ClickStore interface {
+	InsertClicks(clicks []Click) error
+	IterateClicks(shortKey string, from, to time.Time, fn func(c Click) error) error
+}
+
+type ClickCountStore interface {
+	AddClickCounts(counts []ClickCount) error
+	FetchClickCounts(shortKey string, from, to time.Time) ([]ClickCount, error)
+}
+
+type APIKeyStore interface {
+	InsertAPIKey(k *APIKey) error
+	FetchAPIKeyByHash(keyHash string) (*APIKey, error)
+	ListAPIKeys() ([]APIKey, error)
+	RevokeAPIKey(id int64) error
+}
+
+// ShortKeyCounter counts the short URLs whose key has the given length.
+type ShortKeyCounter interface {
+	CountShortKeys(length int) (int64, error)
+}
+
+type KeySequenceStore interface {
+	ReserveKeySequence(n int64) (int64, error)
+}
+
+// KeyPoolStore holds pre-generated keys that are not used by any short URL.
+// TakePooledKeys claims up to n keys, so every pooled key is handed out only
+// once.
+type KeyPoolStore interface {
+	TakePooledKeys(n int) ([]string, error)
+	AddPooledKeys(keys []string) (int, error)
+	CountPooledKeys() (int, error)
+}
+
+type Store interface {
+	URLStore
+	KeySequenceStore
+	KeyPoolStore
+	ClickStore
+	ClickCountStore
+	APIKeyStore
+}
@@ -1,24 +1,30 @@
 package models
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"net/url"
 	"os"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/Conero007/url-shortener/constants"
-	"github.com/go-sql-driver/mysql"
 )
 
 type ShortenURL struct {
-	ID          int64     `json:"-"`
-	ShortKey    string    `json:"-"`
-	OriginalURL string    `json:"original_url"`
-	ShortURL    string    `json:"short_url"`
-	ExpireTime  time.Time `json:"expire_time"`
+	ID            int64      `json:"-"`
+	ShortKey      string     `json:"-"`
+	OriginalURL   string     `json:"original_url"`
+	NormalizedURL string     `json:"-"`
+	ShortURL      string     `json:"short_url"`
+	ExpireTime    *time.Time `json:"expire_time"`
+	Tags          []string   `json:"tags,omitempty"`
+	RedirectType  int        `json:"redirect_type"`
+	APIKeyID      int64      `json:"-"`
+	Disabled      bool       `json:"-"`
+	ArchivedAt    *time.Time `json:"-"`
+	CreatedAt     time.Time  `json:"-"`
+	ClickCount    int64      `json:"-"`
 }
 
 func GetShortenURL(originalURL string) *ShortenURL {
@@ -27,64 +33,165 @@ func GetShortenURL(originalURL string) *ShortenURL {
 	}
 }
 
-func (u *ShortenURL) CreateShortURL(db *sql.DB) error {
-	customShortKey := true
-	if u.ShortKey == "" {
-		customShortKey = false
-		u.generateShortKey(false)
+// KeyGenerator produces candidate short keys for URLs. Every generated key
+// that turns out to be taken is reported back through RecordCollision.
+type KeyGenerator interface {
+	GenerateKey(url string, attempt int) (string, error)
+	RecordCollision(exhausted bool)
+}
+
+func (u *ShortenURL) CreateShortURL(store URLInserter, keys KeyGenerator) error {
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+	if u.NormalizedURL == "" {
+		u.NormalizedURL = u.OriginalURL
 	}
 
-	if u.ExpireTime.IsZero() {
-		u.updateExpireTime()
+	var err error
+	if u.ShortKey != "" {
+		err = store.InsertShortURL(u)
+	} else {
+		err = u.insertWithGeneratedKey(store, keys)
 	}
 
-	var attemptCounter int
-	var mysqlErr *mysql.MySQLError
-	query := "INSERT INTO urls(original_url, short_key, expire_time) VALUES(?, ?, ?);"
+	u.GenerateShortURL()
 
-	_, err := db.Exec(query, u.OriginalURL, u.ShortKey, u.ExpireTime)
+	return err
+}
 
-	for !customShortKey && err != nil && errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 && attemptCounter < constants.GENERATE_SHORT_KEY_MAX_ATTEMPT {
-		attemptCounter++
-		u.generateShortKey(true)
-		_, err = db.Exec(query, u.OriginalURL, u.ShortKey, u.ExpireTime)
+func (u *ShortenURL) insertWithGeneratedKey(store URLInserter, keys KeyGenerator) error {
+	for attempt := 0; ; attempt++ {
+		key, err := keys.GenerateKey(u.destination(), attempt)
+		if err != nil {
+			return err
+		}
+
+		u.ShortKey = key
+		if err = store.InsertShortURL(u); !errors.Is(err, ErrShortKeyExists) {
+			return err
+		}
+
+		exhausted := attempt >= constants.GENERATE_SHORT_KEY_MAX_ATTEMPT
+		keys.RecordCollision(exhausted)
+		if exhausted {
+			return ErrNoShortKeyLeft
+		}
 	}
+}
 
-	u.generateShortURL()
+// CreateShortURLs inserts the urls, skipping nil ones, through batches from
+// store and returns the error of each url, nil when it was inserted. Keys are
+// generated before a batch is opened, and urls whose generated key was taken
+// get a new one in the next batch.
+func CreateShortURLs(store URLStore, urls []*ShortenURL, keys KeyGenerator) ([]error, error) {
+	errs := make([]error, len(urls))
+	generated := make([]bool, len(urls))
+
+	var pending []int
+	for i, u := range urls {
+		if u == nil {
+			continue
+		}
+		if u.CreatedAt.IsZero() {
+			u.CreatedAt = time.Now().UTC()
+		}
+		if u.NormalizedURL == "" {
+			u.NormalizedURL = u.OriginalURL
+		}
+		generated[i] = u.ShortKey == ""
+		pending = append(pending, i)
+	}
 
-	return err
+	for attempt := 0; len(pending) > 0; attempt++ {
+		for _, i := range pending {
+			if generated[i] {
+				urls[i].ShortKey, errs[i] = keys.GenerateKey(urls[i].destination(), attempt)
+			}
+		}
+
+		batch, err := store.BeginBatch()
+		if err != nil {
+			return nil, err
+		}
+
+		var retry []int
+		for _, i := range pending {
+			if errs[i] != nil {
+				continue
+			}
+
+			errs[i] = batch.InsertShortURL(urls[i])
+			urls[i].GenerateShortURL()
+			if !generated[i] || !errors.Is(errs[i], ErrShortKeyExists) {
+				continue
+			}
+
+			exhausted := attempt >= constants.GENERATE_SHORT_KEY_MAX_ATTEMPT
+			keys.RecordCollision(exhausted)
+			if exhausted {
+				errs[i] = ErrNoShortKeyLeft
+			} else {
+				errs[i] = nil
+				retry = append(retry, i)
+			}
+		}
+
+		if err := batch.Commit(); err != nil {
+			return nil, err
+		}
+		pending = retry
+	}
+
+	return errs, nil
 }
 
-func (u *ShortenURL) FetchShortURLData(db *sql.DB) {
-	query := "SELECT id, original_url, short_key, expire_time FROM urls WHERE short_key = ? LIMIT 1;"
-	db.QueryRow(query, u.ShortKey).Scan(&u.ID, &u.OriginalURL, &u.ShortKey, &u.ExpireTime)
+func (u *ShortenURL) Expired() bool {
+	return u.ArchivedAt != nil || (u.ExpireTime != nil && u.ExpireTime.Before(time.Now()))
 }
 
-func (u *ShortenURL) DeleteShortURLData(db *sql.DB, wg *sync.WaitGroup) {
-	defer wg.Done()
+// destination is the URL links are compared and keyed by.
+func (u *ShortenURL) destination() string {
+	if u.NormalizedURL != "" {
+		return u.NormalizedURL
+	}
+	return u.OriginalURL
+}
 
-	query := "DELETE FROM urls WHERE id = ? LIMIT 1;"
-	if _, err := db.Exec(query, u.ID); err != nil {
-		log.Printf("[Error] Could not Delete row %d. ERROR: %s", u.ID, err.Error())
+// Domain returns the lowercased host of the normalized URL.
+func (u *ShortenURL) Domain() string {
+	parsed, err := url.Parse(u.destination())
+	if err != nil {
+		return ""
 	}
+	return strings.ToLower(parsed.Hostname())
 }
 
-func (u *ShortenURL) generateShortKey(retry bool) {
-	var md5Result string
-	if retry {
-		md5Result = calculateMD5(u.OriginalURL + generateRandomKey())
-	} else {
-		md5Result = calculateMD5(u.OriginalURL)
+func (u *ShortenURL) FetchShortURLData(store URLStore) error {
+	if err := store.FetchShortURL(u); err != nil {
+		return err
 	}
-	base62Result := stringToBase62(md5Result)
-	u.ShortKey = base62Result[:constants.SHORT_KEY_LENGTH]
+	u.GenerateShortURL()
+	return nil
+}
+
+func (u *ShortenURL) FetchActiveShortURLData(store URLStore) error {
+	if err := store.FetchActiveShortURLByURL(u); err != nil {
+		return err
+	}
+	u.GenerateShortURL()
+	return nil
+}
+
+func (u *ShortenURL) UpdateShortURLData(store URLStore) error {
+	return store.UpdateShortURL(u)
 }
 
-func (u *ShortenURL) generateShortURL() {
+func (u *ShortenURL) GenerateShortURL() {
 	u.ShortURL = fmt.Sprintf("http://%s:%s/%s", os.Getenv("APP_URL"), os.Getenv("PORT"), u.ShortKey)
 }
 
-func (u *ShortenURL) updateExpireTime() {
+func DefaultExpireTime() time.Time {
 	t := FetchMaxExpireTime()
-	u.ExpireTime = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
 }
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const memorySweepThreshold = 100000
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket)}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, rate Rate) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= memorySweepThreshold {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: float64(rate.Limit), updated: now, period: rate.Period}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(rate.Limit), b.tokens+elapsed*rate.tokensPerSecond())
+	b.updated = now
+	b.period = rate.Period
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return newResult(rate, b.tokens, allowed), nil
+}
+
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > b.period {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+func ParseRate(s string) (Rate, error) {
+	limit, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected <limit>/<period>", s)
+	}
+
+	l, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil || l <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate limit %q", limit)
+	}
+
+	p, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || p <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate period %q", period)
+	}
+
+	return Rate{Limit: l, Period: p}, nil
+}
+
+func (r Rate) tokensPerSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+func newResult(rate Rate, tokens float64, allowed bool) Result {
+	perSecond := rate.tokensPerSecond()
+
+	res := Result{
+		Allowed:    allowed,
+		Limit:      rate.Limit,
+		Remaining:  int(tokens),
+		ResetAfter: time.Duration((float64(rate.Limit) - tokens) / perSecond * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	}
+	return res
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, rate Rate) (Result, error)
+}
+
+type FallbackLimiter struct {
+	Primary  Limiter
+	Fallback Limiter
+}
+
+func (l FallbackLimiter) Allow(ctx context.Context, key string, rate Rate) (Result, error) {
+	res, err := l.Primary.Allow(ctx, key, rate)
+	if err == nil {
+		return res, nil
+	}
+
+	log.Println("[Error] Rate limiter unavailable, falling back to in-memory limiter ", err)
+	return l.Fallback.Allow(ctx, key, rate)
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var tokenBucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local per_ms = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil then
+	tokens = limit
+	updated = now
+end
+
+tokens = math.min(limit, tokens + math.max(0, now - updated) * per_ms)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], ttl)
+
+return {allowed, tostring(tokens)}
+`)
+
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rate Rate) (Result, error) {
+	perMillisecond := rate.tokensPerSecond() / 1000
+	now := time.Now().UnixMilli()
+
+	res, err := tokenBucketScript.Run(ctx, l.client, []string{key}, rate.Limit, perMillisecond, now, rate.Period.Milliseconds()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allowed, _ := res[0].(int64)
+	remaining, _ := res[1].(string)
+
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return newResult(rate, tokens, allowed == 1), nil
+}
@@ -0,0 +1,103 @@
+package reserved
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrReservedKey = errors.New("short key is reserved")
+	ErrBlockedWord = errors.New("short key contains a blocked word")
+)
+
+// routeNames are the paths the service uses or is likely to use, so links can
+// never shadow them.
+var routeNames = []string{
+	"about", "account", "admin", "api", "app", "assets", "auth", "batch", "dashboard",
+	"docs", "export", "favicon", "graphql", "health", "healthz", "help", "import",
+	"links", "login", "logout", "metrics", "oauth", "register", "robots", "settings",
+	"shorten", "signup", "sitemap", "static", "stats", "status", "www",
+}
+
+var profanity = []string{
+	"bitch", "cunt", "fag", "fuck", "nazi", "nigg", "rape", "shit", "slut", "twat",
+	"wank", "whore",
+}
+
+// innocentWords are ordinary words containing a profane one, such as "grape",
+// which are not matched as blocked words.
+var innocentWords = []string{
+	"drape", "grape", "niggl", "rapese", "scrape", "scunthorpe", "snigger", "swank",
+	"therap", "trapez",
+}
+
+// leet maps digits commonly used in place of letters, so "sh1t" is caught
+// like "shit".
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b")
+
+// Registry decides which short keys links may not use: the built-in route
+// names and configured keys, compared case-insensitively, and keys containing
+// a blocked word anywhere outside an allowed word.
+type Registry struct {
+	keys    map[string]bool
+	words   []string
+	allowed *strings.Replacer
+}
+
+// New returns a registry reserving the route names and keys and blocking the
+// words, plus the built-in profanity list when profanityFilter is set. Blocked
+// words inside allowedWords, or inside the built-in innocent words when the
+// profanity filter is on, do not count.
+func New(keys, words, allowedWords []string, profanityFilter bool) *Registry {
+	r := &Registry{keys: make(map[string]bool)}
+	for _, key := range append(routeNames, keys...) {
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			r.keys[key] = true
+		}
+	}
+
+	if profanityFilter {
+		words = append(words, profanity...)
+		allowedWords = append(allowedWords, innocentWords...)
+	}
+	r.words = normalizeWords(words)
+
+	// Allowed words are cut out of the key before blocked words are looked
+	// for, and the separator keeps the remaining parts from joining up.
+	var oldnew []string
+	for _, word := range normalizeWords(allowedWords) {
+		oldnew = append(oldnew, word, "-")
+	}
+	r.allowed = strings.NewReplacer(oldnew...)
+	return r
+}
+
+// Check returns ErrReservedKey or ErrBlockedWord when links may not use key.
+func (r *Registry) Check(key string) error {
+	key = strings.ToLower(key)
+	if r.keys[key] {
+		return ErrReservedKey
+	}
+
+	key = r.allowed.Replace(leet.Replace(key))
+	for _, word := range r.words {
+		if strings.Contains(key, word) {
+			return ErrBlockedWord
+		}
+	}
+	return nil
+}
+
+func normalizeWords(words []string) []string {
+	var normalized []string
+	for _, word := range words {
+		if word = leet.Replace(strings.ToLower(strings.TrimSpace(word))); word != "" {
+			normalized = append(normalized, word)
+		}
+	}
+	return normalized
+}
+
+func (r *Registry) Allowed(key string) bool {
+	return r.Check(key) == nil
+}
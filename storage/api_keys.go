@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+const apiKeyColumns = "id, name, prefix, key_hash, max_ttl_seconds, allow_no_expiry, created_at, revoked_at"
+
+func scanAPIKey(row rowScanner, k *models.APIKey) error {
+	var maxTTL sql.NullInt64
+	var revokedAt sql.NullTime
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.KeyHash, &maxTTL, &k.AllowNoExpiry, &k.CreatedAt, &revokedAt); err != nil {
+		return err
+	}
+	k.MaxTTL = time.Duration(maxTTL.Int64) * time.Second
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return nil
+}
+
+func (s *SQLStore) InsertAPIKey(k *models.APIKey) error {
+	query := "INSERT INTO api_keys(name, prefix, key_hash, max_ttl_seconds, allow_no_expiry, created_at) VALUES(?, ?, ?, ?, ?, ?)"
+
+	maxTTL := nullInt64(int64(k.MaxTTL / time.Second))
+	id, err := s.insert(s.DB, query, k.Name, k.Prefix, k.KeyHash, maxTTL, k.AllowNoExpiry, k.CreatedAt)
+	k.ID = id
+	return err
+}
+
+func (s *SQLStore) FetchAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	var k models.APIKey
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE key_hash = ? LIMIT 1"
+
+	err := scanAPIKey(s.queryRow(query, keyHash), &k)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrAPIKeyNotFound
+	}
+	return &k, err
+}
+
+func (s *SQLStore) ListAPIKeys() ([]models.APIKey, error) {
+	rows, err := s.DB.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *SQLStore) RevokeAPIKey(id int64) error {
+	res, err := s.exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrAPIKeyNotFound
+	}
+	return nil
+}
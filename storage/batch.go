@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+type sqlBatch struct {
+	store *SQLStore
+	tx    *sql.Tx
+}
+
+func (s *SQLStore) BeginBatch() (models.URLBatch, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlBatch{store: s, tx: tx}, nil
+}
+
+// InsertShortURL runs each insert inside a savepoint so a failure, such as a
+// duplicate short key, does not abort the rest of the transaction.
+func (b *sqlBatch) InsertShortURL(u *models.ShortenURL) error {
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+
+	if _, err := b.tx.Exec("SAVEPOINT batch_item"); err != nil {
+		return err
+	}
+
+	if err := b.store.insertShortURL(b.tx, u); err != nil {
+		if _, rollbackErr := b.tx.Exec("ROLLBACK TO SAVEPOINT batch_item"); rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+
+	_, err := b.tx.Exec("RELEASE SAVEPOINT batch_item")
+	return err
+}
+
+func (b *sqlBatch) Commit() error {
+	return b.tx.Commit()
+}
+
+func (b *sqlBatch) Rollback() error {
+	return b.tx.Rollback()
+}
+
+type memoryBatch struct {
+	store    *MemoryStore
+	inserted []string
+}
+
+func (s *MemoryStore) BeginBatch() (models.URLBatch, error) {
+	return &memoryBatch{store: s}, nil
+}
+
+func (b *memoryBatch) InsertShortURL(u *models.ShortenURL) error {
+	if err := b.store.InsertShortURL(u); err != nil {
+		return err
+	}
+	b.inserted = append(b.inserted, u.ShortKey)
+	return nil
+}
+
+func (b *memoryBatch) Commit() error {
+	b.inserted = nil
+	return nil
+}
+
+func (b *memoryBatch) Rollback() error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	for _, key := range b.inserted {
+		delete(b.store.urls, key)
+	}
+	b.inserted = nil
+	return nil
+}
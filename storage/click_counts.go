@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+func (s *SQLStore) AddClickCounts(counts []models.ClickCount) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := s.Dialect.rebind(s.Dialect.upsertClickCount)
+	totalQuery := s.Dialect.rebind("UPDATE urls SET click_count = click_count + ? WHERE short_key = ?")
+	for _, c := range counts {
+		if _, err := tx.Exec(query, c.ShortKey, c.Day.Format("2006-01-02"), c.Clicks, c.UniqueVisitors); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(totalQuery, c.Clicks, c.ShortKey); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FetchClickCounts returns the counts of the days starting in [from, to).
+func (s *SQLStore) FetchClickCounts(shortKey string, from, to time.Time) ([]models.ClickCount, error) {
+	query := "SELECT short_key, day, clicks, unique_visitors FROM click_counts WHERE short_key = ? AND day >= ? AND day < ? ORDER BY day"
+
+	rows, err := s.DB.Query(s.Dialect.rebind(query), shortKey, firstDayFrom(from).Format("2006-01-02"), firstDayFrom(to).Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.ClickCount
+	for rows.Next() {
+		var c models.ClickCount
+		if err := rows.Scan(&c.ShortKey, &c.Day, &c.Clicks, &c.UniqueVisitors); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// firstDayFrom returns the start of the first day starting at or after t. Days
+// are stored as dates, so they are compared with dates rather than timestamps.
+func firstDayFrom(t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	if day.Before(t) {
+		day = day.Add(24 * time.Hour)
+	}
+	return day
+}
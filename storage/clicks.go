@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+func (s *SQLStore) InsertClicks(clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, 0, len(clicks))
+	args := make([]interface{}, 0, len(clicks)*7)
+	for _, c := range clicks {
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, c.ShortKey, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.Country, c.DeviceClass, c.VisitorID)
+	}
+
+	query := "INSERT INTO clicks(short_key, clicked_at, referrer, user_agent, country, device_class, visitor_id) VALUES " + strings.Join(placeholders, ", ")
+	_, err := s.exec(query, args...)
+	return err
+}
+
+func (s *SQLStore) IterateClicks(shortKey string, from, to time.Time, fn func(c models.Click) error) error {
+	query := "SELECT short_key, clicked_at, referrer, user_agent, country, device_class, visitor_id FROM clicks WHERE short_key = ? AND clicked_at >= ? AND clicked_at < ? ORDER BY clicked_at"
+
+	rows, err := s.DB.Query(s.Dialect.rebind(query), shortKey, from.UTC(), to.UTC())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Click
+		if err := rows.Scan(&c.ShortKey, &c.ClickedAt, &c.Referrer, &c.UserAgent, &c.Country, &c.DeviceClass, &c.VisitorID); err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/Conero007/url-shortener/database"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type Dialect struct {
+	Name              string
+	returningID       bool
+	upsertClickCount  string
+	insertPooledKey   string
+	skipLocked        string
+	isUniqueViolation func(err error) bool
+}
+
+var (
+	MySQL = Dialect{
+		Name:             "mysql",
+		upsertClickCount: "INSERT INTO click_counts(short_key, day, clicks, unique_visitors) VALUES(?, ?, ?, ?) ON DUPLICATE KEY UPDATE clicks = clicks + VALUES(clicks), unique_visitors = GREATEST(unique_visitors, VALUES(unique_visitors))",
+		insertPooledKey:  "INSERT IGNORE INTO key_pool(short_key) SELECT ? FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM urls WHERE short_key = ?)",
+		skipLocked:       " FOR UPDATE SKIP LOCKED",
+		isUniqueViolation: func(err error) bool {
+			var mysqlErr *mysql.MySQLError
+			return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+		},
+	}
+
+	Postgres = Dialect{
+		Name:             "postgres",
+		returningID:      true,
+		upsertClickCount: "INSERT INTO click_counts(short_key, day, clicks, unique_visitors) VALUES(?, ?, ?, ?) ON CONFLICT (short_key, day) DO UPDATE SET clicks = click_counts.clicks + EXCLUDED.clicks, unique_visitors = GREATEST(click_counts.unique_visitors, EXCLUDED.unique_visitors)",
+		insertPooledKey:  "INSERT INTO key_pool(short_key) SELECT CAST(? AS VARCHAR(20)) WHERE NOT EXISTS (SELECT 1 FROM urls WHERE short_key = ?) ON CONFLICT DO NOTHING",
+		skipLocked:       " FOR UPDATE SKIP LOCKED",
+		isUniqueViolation: func(err error) bool {
+			var pqErr *pq.Error
+			return errors.As(err, &pqErr) && pqErr.Code == "23505"
+		},
+	}
+
+	SQLite = Dialect{
+		Name:             "sqlite",
+		upsertClickCount: "INSERT INTO click_counts(short_key, day, clicks, unique_visitors) VALUES(?, ?, ?, ?) ON CONFLICT (short_key, day) DO UPDATE SET clicks = click_counts.clicks + excluded.clicks, unique_visitors = MAX(click_counts.unique_visitors, excluded.unique_visitors)",
+		insertPooledKey:  "INSERT OR IGNORE INTO key_pool(short_key) SELECT ? WHERE NOT EXISTS (SELECT 1 FROM urls WHERE short_key = ?)",
+		isUniqueViolation: func(err error) bool {
+			var sqliteErr *sqlite.Error
+			return errors.As(err, &sqliteErr) &&
+				(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+		},
+	}
+)
+
+func (d Dialect) rebind(query string) string {
+	return database.Rebind(d.Name, query)
+}
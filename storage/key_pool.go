@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// TakePooledKeys claims up to n unclaimed keys in its own transaction. Claimed
+// keys stay in the pool until a short URL uses them, so a refill never adds
+// them again while an instance still holds them. Rows locked by another
+// instance taking keys at the same time are skipped rather than waited for.
+func (s *SQLStore) TakePooledKeys(n int) ([]string, error) {
+	var keys []string
+	err := inTransaction(s.DB, func(tx *sql.Tx) error {
+		var err error
+		keys, err = s.claimPooledKeys(tx, n)
+		return err
+	})
+	return keys, err
+}
+
+func (s *SQLStore) claimPooledKeys(tx *sql.Tx, n int) ([]string, error) {
+	rows, err := tx.Query(s.Dialect.rebind("SELECT short_key FROM key_pool WHERE claimed_at IS NULL LIMIT ?"+s.Dialect.skipLocked), n)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	args := []interface{}{time.Now().UTC()}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+		args = append(args, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	if _, err := tx.Exec(s.Dialect.rebind("UPDATE key_pool SET claimed_at = ? WHERE short_key IN ("+in+")"), args...); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// AddPooledKeys adds the keys that are neither in the pool, claimed or not,
+// nor used by a short URL, and returns how many were added.
+func (s *SQLStore) AddPooledKeys(keys []string) (int, error) {
+	var added int
+	err := inTransaction(s.DB, func(tx *sql.Tx) error {
+		for _, key := range keys {
+			res, err := tx.Exec(s.Dialect.rebind(s.Dialect.insertPooledKey), key, key)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err == nil {
+				added += int(n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+func (s *SQLStore) CountPooledKeys() (int, error) {
+	var count int
+	err := s.queryRow("SELECT COUNT(*) FROM key_pool WHERE claimed_at IS NULL").Scan(&count)
+	return count, err
+}
+
+func (s *MemoryStore) TakePooledKeys(n int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key, claimed := range s.pool {
+		if len(keys) == n {
+			break
+		}
+		if !claimed {
+			keys = append(keys, key)
+			s.pool[key] = true
+		}
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) AddPooledKeys(keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added int
+	for _, key := range keys {
+		if _, pooled := s.pool[key]; pooled {
+			continue
+		}
+		if _, used := s.urls[key]; used {
+			continue
+		}
+		s.pool[key] = false
+		added++
+	}
+	return added, nil
+}
+
+func (s *MemoryStore) CountPooledKeys() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int
+	for _, claimed := range s.pool {
+		if !claimed {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+func (s *SQLStore) ListShortURLs(filter models.LinkFilter) ([]models.ShortenURL, error) {
+	var where []string
+	var args []interface{}
+
+	where = append(where, "api_key_id = ?")
+	args = append(args, filter.APIKeyID)
+
+	if filter.Domain != "" {
+		where = append(where, "(domain = ? OR domain LIKE ?)")
+		args = append(args, filter.Domain, "%."+filter.Domain)
+	}
+
+	if filter.Tag != "" {
+		where = append(where, "id IN (SELECT url_id FROM url_tags WHERE tag = ?)")
+		args = append(args, filter.Tag)
+	}
+
+	now := time.Now().UTC()
+	switch filter.Status {
+	case models.LinkStatusActive:
+		where = append(where, "archived_at IS NULL AND (expire_time IS NULL OR expire_time >= ?)")
+		args = append(args, now)
+	case models.LinkStatusExpired:
+		where = append(where, "(archived_at IS NOT NULL OR expire_time < ?)")
+		args = append(args, now)
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.CreatedFrom.UTC())
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.CreatedTo.UTC())
+	}
+
+	column := "created_at"
+	if filter.SortBy == models.LinkSortClickCount {
+		column = "click_count"
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if c := filter.After; c != nil {
+		var value interface{} = c.CreatedAt.UTC()
+		if column == "click_count" {
+			value = c.ClickCount
+		}
+		where = append(where, "("+column+" "+comparison+" ? OR ("+column+" = ? AND id "+comparison+" ?))")
+		args = append(args, value, value, c.ID)
+	}
+
+	query := "SELECT " + urlColumns + " FROM urls WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY " + column + " " + direction + ", id " + direction + " LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := s.DB.Query(s.Dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []models.ShortenURL
+	for rows.Next() {
+		var u models.ShortenURL
+		if err := scanShortURL(rows, &u); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachTags(urls); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// attachTags loads the tags of all urls with a single query.
+func (s *SQLStore) attachTags(urls []models.ShortenURL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]int, len(urls))
+	args := make([]interface{}, 0, len(urls))
+	for i, u := range urls {
+		index[u.ID] = i
+		args = append(args, u.ID)
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	rows, err := s.DB.Query(s.Dialect.rebind("SELECT url_id, tag FROM url_tags WHERE url_id IN ("+in+") ORDER BY tag"), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var urlID int64
+		var tag string
+		if err := rows.Scan(&urlID, &tag); err != nil {
+			return err
+		}
+		if i, ok := index[urlID]; ok {
+			urls[i].Tags = append(urls[i].Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLStore) insertTags(tx *sql.Tx, urlID int64, tags []string) error {
+	query := s.Dialect.rebind("INSERT INTO url_tags(url_id, tag) VALUES(?, ?)")
+	for _, tag := range tags {
+		if _, err := tx.Exec(query, urlID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) fetchTags(urlID int64) ([]string, error) {
+	rows, err := s.DB.Query(s.Dialect.rebind("SELECT tag FROM url_tags WHERE url_id = ? ORDER BY tag"), urlID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
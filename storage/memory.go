@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Conero007/url-shortener/models"
+)
+
+type MemoryStore struct {
+	mu     sync.RWMutex
+	lastID int64
+	urls   map[string]models.ShortenURL
+	clicks []models.Click
+	counts map[string]models.ClickCount
+	keys   []models.APIKey
+	seq    int64
+	// pool maps pooled keys to whether they are claimed.
+	pool map[string]bool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		urls:   make(map[string]models.ShortenURL),
+		counts: make(map[string]models.ClickCount),
+		pool:   make(map[string]bool),
+	}
+}
+
+func (s *MemoryStore) InsertShortURL(u *models.ShortenURL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.urls[u.ShortKey]; ok {
+		return models.ErrShortKeyExists
+	}
+
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+
+	s.lastID++
+	u.ID = s.lastID
+	s.urls[u.ShortKey] = copyShortURL(u)
+	delete(s.pool, u.ShortKey)
+
+	return nil
+}
+
+func (s *MemoryStore) FetchShortURL(u *models.ShortenURL) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.urls[u.ShortKey]
+	if !ok {
+		return models.ErrShortURLNotFound
+	}
+
+	*u = stored
+	return nil
+}
+
+func (s *MemoryStore) FetchActiveShortURLByURL(u *models.ShortenURL) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *models.ShortenURL
+	for _, stored := range s.urls {
+		if stored.APIKeyID != u.APIKeyID || stored.NormalizedURL != u.NormalizedURL || stored.Disabled || stored.Expired() {
+			continue
+		}
+		if found == nil || stored.ID > found.ID {
+			stored := stored
+			found = &stored
+		}
+	}
+
+	if found == nil {
+		return models.ErrShortURLNotFound
+	}
+	*u = copyShortURL(found)
+	return nil
+}
+
+func (s *MemoryStore) UpdateShortURL(u *models.ShortenURL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.urls[u.ShortKey]
+	if !ok || stored.ID != u.ID {
+		return models.ErrShortURLNotFound
+	}
+
+	updated := copyShortURL(u)
+	updated.CreatedAt = stored.CreatedAt
+	updated.ClickCount = stored.ClickCount
+	s.urls[u.ShortKey] = updated
+	return nil
+}
+
+func (s *MemoryStore) ShortKeyExists(shortKey string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.urls[shortKey]
+	return ok, nil
+}
+
+func (s *MemoryStore) CountShortKeys(length int) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for key := range s.urls {
+		if len(key) == length {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) ListShortURLs(filter models.LinkFilter) ([]models.ShortenURL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var urls []models.ShortenURL
+	for _, u := range s.urls {
+		if u.APIKeyID != filter.APIKeyID {
+			continue
+		}
+		if domain := u.Domain(); filter.Domain != "" && domain != filter.Domain && !strings.HasSuffix(domain, "."+filter.Domain) {
+			continue
+		}
+		if filter.Tag != "" && !slices.Contains(u.Tags, filter.Tag) {
+			continue
+		}
+		expired := u.ArchivedAt != nil || (u.ExpireTime != nil && u.ExpireTime.Before(now))
+		if (filter.Status == models.LinkStatusActive && expired) || (filter.Status == models.LinkStatusExpired && !expired) {
+			continue
+		}
+		if (!filter.CreatedFrom.IsZero() && u.CreatedAt.Before(filter.CreatedFrom)) || (!filter.CreatedTo.IsZero() && !u.CreatedAt.Before(filter.CreatedTo)) {
+			continue
+		}
+		if filter.After != nil && !linkBefore(*filter.After, u.Cursor(), filter) {
+			continue
+		}
+		urls = append(urls, copyShortURL(&u))
+	}
+
+	sort.Slice(urls, func(i, j int) bool { return linkBefore(urls[i].Cursor(), urls[j].Cursor(), filter) })
+
+	if len(urls) > filter.Limit {
+		urls = urls[:filter.Limit]
+	}
+	return urls, nil
+}
+
+// linkBefore reports whether a comes before b in the filter's sort order.
+func linkBefore(a, b models.LinkCursor, filter models.LinkFilter) bool {
+	if !filter.Ascending {
+		a, b = b, a
+	}
+	if filter.SortBy == models.LinkSortClickCount {
+		if a.ClickCount != b.ClickCount {
+			return a.ClickCount < b.ClickCount
+		}
+	} else if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+func (s *MemoryStore) ArchiveExpiredShortURLs(before time.Time, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := s.selectURLs(limit, func(u models.ShortenURL) *time.Time {
+		if u.ArchivedAt == nil && u.ExpireTime != nil && u.ExpireTime.Before(before) {
+			return u.ExpireTime
+		}
+		return nil
+	})
+
+	now := time.Now().UTC()
+	keys := make([]string, 0, len(expired))
+	for _, u := range expired {
+		u.ArchivedAt = &now
+		s.urls[u.ShortKey] = u
+		keys = append(keys, u.ShortKey)
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) PurgeArchivedShortURLs(before time.Time, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archived := s.selectURLs(limit, func(u models.ShortenURL) *time.Time {
+		if u.ArchivedAt != nil && u.ArchivedAt.Before(before) {
+			return u.ArchivedAt
+		}
+		return nil
+	})
+
+	purged := make(map[string]bool, len(archived))
+	keys := make([]string, 0, len(archived))
+	for _, u := range archived {
+		delete(s.urls, u.ShortKey)
+		purged[u.ShortKey] = true
+		keys = append(keys, u.ShortKey)
+	}
+
+	clicks := s.clicks[:0]
+	for _, c := range s.clicks {
+		if !purged[c.ShortKey] {
+			clicks = append(clicks, c)
+		}
+	}
+	s.clicks = clicks
+
+	for key, c := range s.counts {
+		if purged[c.ShortKey] {
+			delete(s.counts, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// selectURLs returns up to limit urls for which orderBy returns a time,
+// oldest first.
+func (s *MemoryStore) selectURLs(limit int, orderBy func(u models.ShortenURL) *time.Time) []models.ShortenURL {
+	var selected []models.ShortenURL
+	for _, u := range s.urls {
+		if orderBy(u) != nil {
+			selected = append(selected, u)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return orderBy(selected[i]).Before(*orderBy(selected[j])) })
+
+	if len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected
+}
+
+func (s *MemoryStore) ReserveKeySequence(n int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := s.seq
+	s.seq += n
+	return start, nil
+}
+
+func (s *MemoryStore) Truncate(table string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch table {
+	case "urls":
+		s.lastID = 0
+		s.urls = make(map[string]models.ShortenURL)
+	case "clicks":
+		s.clicks = nil
+	case "click_counts":
+		s.counts = make(map[string]models.ClickCount)
+	case "api_keys":
+		s.keys = nil
+	case "key_pool":
+		s.pool = make(map[string]bool)
+	}
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) InsertClicks(clicks []models.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks = append(s.clicks, clicks...)
+	return nil
+}
+
+func (s *MemoryStore) IterateClicks(shortKey string, from, to time.Time, fn func(c models.Click) error) error {
+	s.mu.RLock()
+	var clicks []models.Click
+	for _, c := range s.clicks {
+		if c.ShortKey == shortKey && !c.ClickedAt.Before(from) && c.ClickedAt.Before(to) {
+			clicks = append(clicks, c)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, c := range clicks {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) AddClickCounts(counts []models.ClickCount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range counts {
+		key := c.ShortKey + "|" + c.Day.Format("2006-01-02")
+		existing := s.counts[key]
+		existing.ShortKey = c.ShortKey
+		existing.Day = c.Day
+		existing.Clicks += c.Clicks
+		if u, ok := s.urls[c.ShortKey]; ok {
+			u.ClickCount += c.Clicks
+			s.urls[c.ShortKey] = u
+		}
+		if c.UniqueVisitors > existing.UniqueVisitors {
+			existing.UniqueVisitors = c.UniqueVisitors
+		}
+		s.counts[key] = existing
+	}
+	return nil
+}
+
+func (s *MemoryStore) FetchClickCounts(shortKey string, from, to time.Time) ([]models.ClickCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var counts []models.ClickCount
+	for _, c := range s.counts {
+		if c.ShortKey == shortKey && !c.Day.Before(from) && c.Day.Before(to) {
+			counts = append(counts, c)
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Day.Before(counts[j].Day) })
+	return counts, nil
+}
+
+func (s *MemoryStore) InsertAPIKey(k *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k.ID = int64(len(s.keys) + 1)
+	s.keys = append(s.keys, *k)
+	return nil
+}
+
+func (s *MemoryStore) FetchAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.KeyHash == keyHash {
+			return &k, nil
+		}
+	}
+	return nil, models.ErrAPIKeyNotFound
+}
+
+func (s *MemoryStore) ListAPIKeys() ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.APIKey(nil), s.keys...), nil
+}
+
+func (s *MemoryStore) RevokeAPIKey(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		if s.keys[i].ID == id && s.keys[i].RevokedAt == nil {
+			now := time.Now().UTC()
+			s.keys[i].RevokedAt = &now
+			return nil
+		}
+	}
+	return models.ErrAPIKeyNotFound
+}
+
+// copyShortURL keeps stored urls from sharing time pointers with the caller.
+func copyShortURL(u *models.ShortenURL) models.ShortenURL {
+	stored := *u
+	if u.ExpireTime != nil {
+		expireTime := *u.ExpireTime
+		stored.ExpireTime = &expireTime
+	}
+	if u.ArchivedAt != nil {
+		archivedAt := *u.ArchivedAt
+		stored.ArchivedAt = &archivedAt
+	}
+	stored.Tags = append([]string(nil), u.Tags...)
+	return stored
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func openMySQL(addr, user, password, dbName string) (*sql.DB, error) {
+	cfg := mysql.Config{
+		User:      user,
+		Passwd:    password,
+		Net:       "tcp",
+		Addr:      addr,
+		ParseTime: true,
+	}
+
+	if err := createDatabase(cfg, dbName); err != nil {
+		return nil, err
+	}
+
+	cfg.DBName = dbName
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	return db, db.Ping()
+}
+
+func createDatabase(cfg mysql.Config, dbName string) error {
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("CREATE DATABASE IF NOT EXISTS " + dbName)
+	return err
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"database/sql"
+	"net/url"
+
+	_ "github.com/lib/pq"
+)
+
+func openPostgres(addr, user, password, dbName string) (*sql.DB, error) {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     addr,
+		Path:     dbName,
+		RawQuery: "sslmode=disable",
+	}
+
+	db, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return db, db.Ping()
+}
@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Conero007/url-shortener/database"
+	"github.com/Conero007/url-shortener/models"
+)
+
+var errUniqueViolation = errors.New("unique constraint violation")
+
+type SQLStore struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+func NewSQLStore(driver, addr, user, password, dbName string) (*SQLStore, error) {
+	var db *sql.DB
+	var dialect Dialect
+	var err error
+
+	switch driver {
+	case MySQL.Name:
+		db, err = openMySQL(addr, user, password, dbName)
+		dialect = MySQL
+	case Postgres.Name:
+		db, err = openPostgres(addr, user, password, dbName)
+		dialect = Postgres
+	case SQLite.Name:
+		db, err = openSQLite(dbName)
+		dialect = SQLite
+	default:
+		return nil, fmt.Errorf("unsupported DB driver %q", driver)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLStore{DB: db, Dialect: dialect}, nil
+}
+
+func (s *SQLStore) Migrate() error {
+	return database.RunMigrations(s.DB, s.Dialect.Name)
+}
+
+func (s *SQLStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.DB.Exec(s.Dialect.rebind(query), args...)
+}
+
+func (s *SQLStore) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.DB.QueryRow(s.Dialect.rebind(query), args...)
+}
+
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *SQLStore) insert(db querier, query string, args ...interface{}) (int64, error) {
+	var id int64
+	var err error
+
+	if s.Dialect.returningID {
+		err = db.QueryRow(s.Dialect.rebind(query+" RETURNING id"), args...).Scan(&id)
+	} else {
+		var res sql.Result
+		if res, err = db.Exec(s.Dialect.rebind(query), args...); err == nil {
+			id, err = res.LastInsertId()
+		}
+	}
+
+	if err != nil && s.Dialect.isUniqueViolation(err) {
+		return 0, errUniqueViolation
+	}
+	return id, err
+}
+
+const urlColumns = "id, original_url, normalized_url, short_key, expire_time, api_key_id, disabled, archived_at, created_at, click_count, redirect_type"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanShortURL(row rowScanner, u *models.ShortenURL) error {
+	var expireTime, archivedAt sql.NullTime
+	var apiKeyID sql.NullInt64
+	if err := row.Scan(&u.ID, &u.OriginalURL, &u.NormalizedURL, &u.ShortKey, &expireTime, &apiKeyID, &u.Disabled, &archivedAt, &u.CreatedAt, &u.ClickCount, &u.RedirectType); err != nil {
+		return err
+	}
+	u.ExpireTime = timePtr(expireTime)
+	u.ArchivedAt = timePtr(archivedAt)
+	u.APIKeyID = apiKeyID.Int64
+	return nil
+}
+
+func nullInt64(i int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: i, Valid: i != 0}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func (s *SQLStore) InsertShortURL(u *models.ShortenURL) error {
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+
+	return inTransaction(s.DB, func(tx *sql.Tx) error {
+		return s.insertShortURL(tx, u)
+	})
+}
+
+func (s *SQLStore) insertShortURL(tx *sql.Tx, u *models.ShortenURL) error {
+	query := "INSERT INTO urls(original_url, normalized_url, short_key, domain, expire_time, api_key_id, disabled, archived_at, created_at, redirect_type) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	id, err := s.insert(tx, query, u.OriginalURL, u.NormalizedURL, u.ShortKey, u.Domain(), nullTime(u.ExpireTime), nullInt64(u.APIKeyID), u.Disabled, nullTime(u.ArchivedAt), u.CreatedAt.UTC(), u.RedirectType)
+	if errors.Is(err, errUniqueViolation) {
+		return models.ErrShortKeyExists
+	} else if err != nil {
+		return err
+	}
+
+	if err := s.insertTags(tx, id, u.Tags); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.Dialect.rebind("DELETE FROM key_pool WHERE short_key = ?"), u.ShortKey); err != nil {
+		return err
+	}
+	u.ID = id
+	return nil
+}
+
+func (s *SQLStore) FetchShortURL(u *models.ShortenURL) error {
+	query := "SELECT " + urlColumns + " FROM urls WHERE short_key = ? LIMIT 1"
+
+	err := scanShortURL(s.queryRow(query, u.ShortKey), u)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrShortURLNotFound
+	} else if err != nil {
+		return err
+	}
+
+	u.Tags, err = s.fetchTags(u.ID)
+	return err
+}
+
+// FetchActiveShortURLByURL fills u with the newest active link of the same
+// owner pointing to the same normalized URL.
+func (s *SQLStore) FetchActiveShortURLByURL(u *models.ShortenURL) error {
+	owner := "api_key_id = ?"
+	args := []interface{}{u.APIKeyID}
+	if u.APIKeyID == 0 {
+		owner, args = "api_key_id IS NULL", nil
+	}
+
+	query := "SELECT " + urlColumns + " FROM urls WHERE " + owner + " AND normalized_url = ? AND disabled = ? AND archived_at IS NULL AND (expire_time IS NULL OR expire_time > ?) ORDER BY id DESC LIMIT 1"
+	args = append(args, u.NormalizedURL, false, time.Now().UTC())
+
+	err := scanShortURL(s.queryRow(query, args...), u)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrShortURLNotFound
+	} else if err != nil {
+		return err
+	}
+
+	u.Tags, err = s.fetchTags(u.ID)
+	return err
+}
+
+func (s *SQLStore) UpdateShortURL(u *models.ShortenURL) error {
+	return inTransaction(s.DB, func(tx *sql.Tx) error {
+		query := "UPDATE urls SET original_url = ?, normalized_url = ?, domain = ?, expire_time = ?, disabled = ?, archived_at = ?, redirect_type = ?, updated_at = ? WHERE id = ?"
+
+		res, err := tx.Exec(s.Dialect.rebind(query), u.OriginalURL, u.NormalizedURL, u.Domain(), nullTime(u.ExpireTime), u.Disabled, nullTime(u.ArchivedAt), u.RedirectType, time.Now().UTC(), u.ID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return models.ErrShortURLNotFound
+		}
+
+		if _, err := tx.Exec(s.Dialect.rebind("DELETE FROM url_tags WHERE url_id = ?"), u.ID); err != nil {
+			return err
+		}
+		return s.insertTags(tx, u.ID, u.Tags)
+	})
+}
+
+func (s *SQLStore) ArchiveExpiredShortURLs(before time.Time, limit int) ([]string, error) {
+	query := "SELECT id, short_key FROM urls WHERE expire_time < ? AND archived_at IS NULL ORDER BY expire_time LIMIT ?"
+
+	return s.updateBatch(query, []interface{}{before.UTC(), limit}, func(tx *sql.Tx, in string, ids, keys []interface{}) error {
+		_, err := tx.Exec(s.Dialect.rebind("UPDATE urls SET archived_at = ? WHERE id IN ("+in+")"), append([]interface{}{time.Now().UTC()}, ids...)...)
+		return err
+	})
+}
+
+func (s *SQLStore) PurgeArchivedShortURLs(before time.Time, limit int) ([]string, error) {
+	query := "SELECT id, short_key FROM urls WHERE archived_at < ? ORDER BY archived_at LIMIT ?"
+
+	return s.updateBatch(query, []interface{}{before.UTC(), limit}, func(tx *sql.Tx, in string, ids, keys []interface{}) error {
+		for _, q := range []string{"DELETE FROM clicks WHERE short_key IN (", "DELETE FROM click_counts WHERE short_key IN ("} {
+			if _, err := tx.Exec(s.Dialect.rebind(q+in+")"), keys...); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(s.Dialect.rebind("DELETE FROM url_tags WHERE url_id IN ("+in+")"), ids...); err != nil {
+			return err
+		}
+		_, err := tx.Exec(s.Dialect.rebind("DELETE FROM urls WHERE id IN ("+in+")"), ids...)
+		return err
+	})
+}
+
+// updateBatch selects up to a batch of (id, short_key) rows and applies fn to
+// them in the same transaction. in is a placeholder list matching the batch.
+func (s *SQLStore) updateBatch(query string, args []interface{}, fn func(tx *sql.Tx, in string, ids, keys []interface{}) error) ([]string, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(s.Dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, keyArgs []interface{}
+	var keys []string
+	for rows.Next() {
+		var id int64
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		keyArgs = append(keyArgs, key)
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	if err := fn(tx, in, ids, keyArgs); err != nil {
+		return nil, err
+	}
+
+	return keys, tx.Commit()
+}
+
+// ReserveKeySequence advances the shared short key sequence by n and returns
+// the first number of the reserved block.
+func (s *SQLStore) ReserveKeySequence(n int64) (int64, error) {
+	var end int64
+	err := inTransaction(s.DB, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(s.Dialect.rebind("UPDATE key_sequences SET value = value + ? WHERE name = 'short_keys'"), n); err != nil {
+			return err
+		}
+		return tx.QueryRow("SELECT value FROM key_sequences WHERE name = 'short_keys'").Scan(&end)
+	})
+	return end - n, err
+}
+
+func (s *SQLStore) ShortKeyExists(shortKey string) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS(SELECT 1 FROM urls WHERE short_key = ?)"
+	err := s.queryRow(query, shortKey).Scan(&exists)
+	return exists, err
+}
+
+func (s *SQLStore) CountShortKeys(length int) (int64, error) {
+	var count int64
+	err := s.queryRow("SELECT COUNT(*) FROM urls WHERE LENGTH(short_key) = ?", length).Scan(&count)
+	return count, err
+}
+
+func (s *SQLStore) Close() error {
+	return s.DB.Close()
+}
+
+func inTransaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, so serialise access through one connection
+	// instead of surfacing "database is locked" errors under load.
+	db.SetMaxOpenConns(1)
+
+	return db, db.Ping()
+}
@@ -0,0 +1,156 @@
+package urlnorm
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidHost is returned for host names that cannot be converted to a
+// valid ASCII host name.
+var ErrInvalidHost = errors.New("invalid host name")
+
+// Punycode parameters from RFC 3492.
+const (
+	base        = 36
+	tMin        = 1
+	tMax        = 26
+	skew        = 38
+	damp        = 700
+	initialBias = 72
+	initialN    = 128
+)
+
+// toASCII converts an internationalized host name to its punycode form, label
+// by label. Empty labels, labels longer than 63 bytes once encoded and
+// internationalized labels with anything but letters, digits, marks and inner
+// hyphens are rejected.
+func toASCII(host string) (string, error) {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if !isASCII(label) {
+			if !validUnicodeLabel(label) {
+				return "", ErrInvalidHost
+			}
+			labels[i] = "xn--" + encodePunycode(label)
+		}
+
+		if labels[i] == "" || len(labels[i]) > 63 {
+			return "", ErrInvalidHost
+		}
+	}
+
+	if ascii := strings.Join(labels, "."); len(ascii) <= 253 {
+		return ascii, nil
+	}
+	return "", ErrInvalidHost
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func validUnicodeLabel(label string) bool {
+	if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") || strings.HasPrefix(label, "xn--") {
+		return false
+	}
+
+	for _, r := range label {
+		if r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodePunycode(s string) string {
+	runes := []rune(s)
+
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := rune(initialN), 0, initialBias
+	for handled < len(runes) {
+		m := rune(0x10FFFF)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+
+		delta += int(m-n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := base; ; k += base {
+				t := k - bias
+				if t < tMin {
+					t = tMin
+				} else if t > tMax {
+					t = tMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punycodeDigit(t+(q-t)%(base-t)))
+				q = (q - t) / (base - t)
+			}
+			out = append(out, punycodeDigit(q))
+
+			bias = adapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+
+		delta++
+		n++
+	}
+
+	return string(out)
+}
+
+func adapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= damp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+
+	k := 0
+	for delta > ((base-tMin)*tMax)/2 {
+		delta /= base - tMin
+		k += base
+	}
+	return k + (base-tMin+1)*delta/(delta+skew)
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
@@ -0,0 +1,60 @@
+package urlnorm
+
+import (
+	"strings"
+	"testing"
+)
+
+// Sample strings from RFC 3492 section 7.1, without the optional case flags.
+func TestEncodePunycode(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"arabic", "ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+		{"simplified chinese", "他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+		{"traditional chinese", "他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+		{"czech", "Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+		{"hebrew", "למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+		{"russian", "почемужеонинеговорятпорусски", "b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+		{"japanese with ascii", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"japanese with hyphens", "安室奈美恵-with-SUPER-MONKEYS", "-with-SUPER-MONKEYS-pc58ag80a8qai00g7n9n"},
+		{"japanese with digit", "ひとつ屋根の下2", "2-u9tlzr9756bt3uc0v"},
+		{"german", "bücher", "bcher-kva"},
+	}
+
+	for _, test := range tests {
+		if got := encodePunycode(test.input); got != test.expected {
+			t.Errorf("%s: expected %q. Got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host, expected string
+		valid          bool
+	}{
+		{"Bücher.Example.", "xn--bcher-kva.example", true},
+		{"www.example.com", "www.example.com", true},
+		{"::1", "::1", true},
+		{"", "", true},
+		{"a..example", "", false},
+		{".example", "", false},
+		{"-bücher.example", "", false},
+		{"bücher-.example", "", false},
+		{"xn--bücher.example", "", false},
+		{"☃.example", "", false},
+		{strings.Repeat("ü", 60) + ".example", "", false},
+		{strings.Repeat("a", 64) + ".example", "", false},
+		{strings.Repeat("a.", 127) + "example", "", false},
+	}
+
+	for _, test := range tests {
+		got, err := NormalizeHost(test.host)
+		if test.valid && (err != nil || got != test.expected) {
+			t.Errorf("Expected %q to normalize to %q. Got %q, %v", test.host, test.expected, got, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("Expected %q to be rejected. Got %q", test.host, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package urlnorm
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalizer rewrites URLs to a canonical form so that URLs pointing to the
+// same resource compare equal.
+type Normalizer struct {
+	stripParams []string
+}
+
+// New returns a Normalizer that also removes the given query parameters. A
+// trailing "*" matches every parameter with that prefix, as in "utm_*".
+func New(stripParams []string) *Normalizer {
+	n := &Normalizer{}
+	for _, param := range stripParams {
+		if param = strings.ToLower(strings.TrimSpace(param)); param != "" {
+			n.stripParams = append(n.stripParams, param)
+		}
+	}
+	return n
+}
+
+// Normalize lowercases the scheme and host, converts internationalized hosts
+// to punycode, drops default ports, cleans dot segments from the path and
+// removes empty and stripped query parameters.
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	if u.Host != "" {
+		host, err := NormalizeHost(u.Hostname())
+		if err != nil {
+			return "", err
+		}
+
+		if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		u.Host = host
+
+		cleanPath(u)
+	}
+
+	u.RawQuery = n.filterQuery(u.RawQuery)
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// NormalizeHost lowercases a host name, drops a trailing dot and converts it
+// to punycode when it is internationalized. IP addresses are only lowercased.
+func NormalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || net.ParseIP(host) != nil {
+		return host, nil
+	}
+	return toASCII(host)
+}
+
+func cleanPath(u *url.URL) {
+	escaped := u.EscapedPath()
+	if escaped == "" {
+		u.Path, u.RawPath = "/", ""
+		return
+	}
+
+	cleaned := path.Clean(escaped)
+	if strings.HasSuffix(escaped, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	if unescaped, err := url.PathUnescape(cleaned); err == nil {
+		u.Path, u.RawPath = unescaped, cleaned
+	}
+}
+
+func (n *Normalizer) filterQuery(rawQuery string) string {
+	var kept []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(name); err == nil && n.stripped(name) {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return strings.Join(kept, "&")
+}
+
+func (n *Normalizer) stripped(name string) bool {
+	name = strings.ToLower(name)
+	for _, param := range n.stripParams {
+		if prefix, ok := strings.CutSuffix(param, "*"); (ok && strings.HasPrefix(name, prefix)) || name == param {
+			return true
+		}
+	}
+	return false
+}
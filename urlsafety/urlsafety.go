@@ -0,0 +1,123 @@
+package urlsafety
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/Conero007/url-shortener/urlnorm"
+)
+
+var (
+	ErrSchemeNotAllowed = errors.New("url scheme not allowed")
+	ErrMissingHost      = errors.New("url has no host")
+	ErrDomainBlocked    = errors.New("url domain is blocked")
+	ErrDomainNotAllowed = errors.New("url domain is not allowed")
+	ErrSelfReference    = errors.New("url points to this shortener")
+	ErrChainedShortener = errors.New("url points to another url shortener")
+)
+
+// Checker decides whether a URL may be used as the destination of a link.
+//
+// Domains are matched against patterns either exactly, as in "example.com",
+// or with a leading "*.", as in "*.example.com", which matches example.com
+// and all of its subdomains.
+type Checker struct {
+	schemes    map[string]bool
+	blocked    []string
+	allowed    []string
+	shorteners []string
+	selfHosts  []string
+}
+
+// New returns a Checker accepting the given schemes. When allowedDomains is
+// not empty, only URLs on those domains are accepted. URLs on selfHosts, the
+// hosts short URLs are served from, or on a shortener domain or any of its
+// subdomains, are rejected.
+func New(schemes, blockedDomains, allowedDomains, shortenerDomains, selfHosts []string) *Checker {
+	c := &Checker{
+		schemes: make(map[string]bool),
+		blocked: normalizePatterns(blockedDomains),
+		allowed: normalizePatterns(allowedDomains),
+	}
+	for _, host := range selfHosts {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		c.selfHosts = append(c.selfHosts, normalizePatterns([]string{host})...)
+	}
+	for _, scheme := range schemes {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			c.schemes[scheme] = true
+		}
+	}
+	for _, domain := range normalizePatterns(shortenerDomains) {
+		c.shorteners = append(c.shorteners, "*."+strings.TrimPrefix(domain, "*."))
+	}
+	return c
+}
+
+// Check returns the reason the URL may not be used, or nil. The URL is
+// expected to be normalized already.
+func (c *Checker) Check(u *url.URL) error {
+	if !c.schemes[strings.ToLower(u.Scheme)] {
+		return ErrSchemeNotAllowed
+	}
+
+	host, err := urlnorm.NormalizeHost(u.Hostname())
+	if err != nil {
+		return err
+	} else if host == "" {
+		if u.Scheme == "http" || u.Scheme == "https" {
+			return ErrMissingHost
+		}
+		return nil
+	}
+
+	switch {
+	case matchAny(host, c.selfHosts):
+		return ErrSelfReference
+	case matchAny(host, c.blocked):
+		return ErrDomainBlocked
+	case len(c.allowed) > 0 && !matchAny(host, c.allowed):
+		return ErrDomainNotAllowed
+	case matchAny(host, c.shorteners):
+		return ErrChainedShortener
+	}
+	return nil
+}
+
+func normalizePatterns(patterns []string) []string {
+	var normalized []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		domain, wildcard := strings.CutPrefix(pattern, "*.")
+		domain, err := urlnorm.NormalizeHost(domain)
+		if err != nil {
+			log.Printf("[Error] Ignoring invalid domain pattern %q", pattern)
+			continue
+		}
+
+		if domain != "" && wildcard {
+			normalized = append(normalized, "*."+domain)
+		} else if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
+
+func matchAny(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}